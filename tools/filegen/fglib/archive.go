@@ -0,0 +1,352 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Generation of files tree into tar and zip archives
+*/
+
+package fglib
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ArchiveEnum
+const (
+	ArchiveTar = iota
+	ArchiveTarGz
+	ArchiveZip
+)
+
+// archiveEntry describes entry of archive. Type of entry is set by mode
+type archiveEntry struct {
+	name     string // relative path with slashes
+	mode     os.FileMode
+	uid      int // not set if negative
+	gid      int
+	size     uint64
+	target   string // target of symlink or hardlink
+	hardlink bool
+}
+
+type archiveWriter interface {
+	io.Closer // writes end of archive. Underlying writer is not closed
+	// Add adds entry. Data of regular file is written with write function
+	Add(entry archiveEntry, write func(w io.Writer) error) error
+}
+
+// unixMode converts os.FileMode to permissions and special bits in Unix format
+func unixMode(mode os.FileMode) int64 {
+	result := int64(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		result |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		result |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		result |= 01000
+	}
+	return result
+}
+
+/* tar archive */
+
+type tarArchiveWriter struct {
+	writer  *tar.Writer
+	gzip    *gzip.Writer // nil if archive is not compressed
+	modTime time.Time
+}
+
+func (w *tarArchiveWriter) Add(entry archiveEntry, write func(w io.Writer) error) error {
+	header := &tar.Header{
+		Name:    entry.name,
+		Mode:    unixMode(entry.mode),
+		ModTime: w.modTime,
+	}
+	if entry.uid >= 0 {
+		header.Uid = entry.uid
+	}
+	if entry.gid >= 0 {
+		header.Gid = entry.gid
+	}
+
+	switch {
+	case entry.hardlink:
+		header.Typeflag = tar.TypeLink
+		header.Linkname = entry.target
+	case entry.mode&os.ModeDir != 0:
+		header.Typeflag = tar.TypeDir
+		header.Name += "/"
+	case entry.mode&os.ModeSymlink != 0:
+		header.Typeflag = tar.TypeSymlink
+		header.Linkname = entry.target
+	case entry.mode&os.ModeNamedPipe != 0:
+		header.Typeflag = tar.TypeFifo
+	default:
+		header.Typeflag = tar.TypeReg
+		header.Size = int64(entry.size)
+	}
+
+	err := w.writer.WriteHeader(header)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to write header of '%s'", entry.name)
+	}
+	if header.Typeflag == tar.TypeReg {
+		return write(w.writer)
+	}
+	return nil
+}
+
+func (w *tarArchiveWriter) Close() error {
+	err := w.writer.Close()
+	if err != nil {
+		return errors.Wrap(err, "Failed to close tar archive")
+	}
+	if w.gzip != nil {
+		err = w.gzip.Close()
+		if err != nil {
+			return errors.Wrap(err, "Failed to close gzip stream")
+		}
+	}
+	return nil
+}
+
+/* zip archive */
+
+type zipArchiveWriter struct {
+	writer  *zip.Writer
+	modTime time.Time
+}
+
+func (w *zipArchiveWriter) Add(entry archiveEntry, write func(w io.Writer) error) error {
+	if entry.hardlink || entry.mode&os.ModeNamedPipe != 0 {
+		return errors.Wrapf(ErrNotSupported, "Failed to add '%s'. Zip format has no hard links and named pipes",
+			entry.name)
+	}
+
+	header := &zip.FileHeader{
+		Name:     entry.name,
+		Method:   zip.Store, // random data is not compressed
+		Modified: w.modTime,
+	}
+	header.SetMode(entry.mode)
+	if entry.mode&os.ModeDir != 0 {
+		header.Name += "/"
+	}
+
+	file, err := w.writer.CreateHeader(header)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to write header of '%s'", entry.name)
+	}
+	switch {
+	case entry.mode&os.ModeDir != 0:
+		return nil
+	case entry.mode&os.ModeSymlink != 0:
+		_, err = io.WriteString(file, entry.target)
+		return err
+	}
+	return write(file)
+}
+
+func (w *zipArchiveWriter) Close() error {
+	err := w.writer.Close()
+	if err != nil {
+		return errors.Wrap(err, "Failed to close zip archive")
+	}
+	return nil
+}
+
+func createArchiveWriter(writer io.Writer, format int) (archiveWriter, error) {
+	modTime := time.Now().Truncate(time.Second)
+	switch format {
+	case ArchiveTar:
+		return &tarArchiveWriter{writer: tar.NewWriter(writer), modTime: modTime}, nil
+	case ArchiveTarGz:
+		gzipWriter := gzip.NewWriter(writer)
+		return &tarArchiveWriter{writer: tar.NewWriter(gzipWriter), gzip: gzipWriter, modTime: modTime}, nil
+	case ArchiveZip:
+		return &zipArchiveWriter{writer: zip.NewWriter(writer), modTime: modTime}, nil
+	}
+	return nil, fmt.Errorf("Invalid archive format %d", format)
+}
+
+/* Generator of archive */
+
+type archiveFilesGenerator struct {
+	*linearFilesGenerator
+	archive archiveWriter
+	dirs    map[string]bool // directories added to archive
+}
+
+// addDir adds directory with parents which are not added yet
+func (g *archiveFilesGenerator) addDir(name string) error {
+	if name == "." || g.dirs[name] {
+		return nil
+	}
+	err := g.addDir(path.Dir(name))
+	if err != nil {
+		return err
+	}
+
+	entry := archiveEntry{name: name, mode: os.ModeDir | 0755}
+	if g.perms != nil {
+		entry.uid, entry.gid = g.perms.getOwner(name)
+		if mode, ok := g.perms.getMode(name, g.perms.dirModes); ok {
+			entry.mode = os.ModeDir | mode
+		}
+	} else {
+		entry.uid, entry.gid = -1, -1
+	}
+	g.dirs[name] = true
+	return g.archive.Add(entry, nil)
+}
+
+func (g *archiveFilesGenerator) addEntry(ctx context.Context, index uint, name string) error {
+	entryType := EntryRegular
+	if g.special != nil {
+		entryType = g.special.GetEntryType(index)
+	}
+
+	entry := archiveEntry{name: name, mode: 0644, uid: -1, gid: -1, size: g.fileSize}
+	if g.perms != nil {
+		entry.uid, entry.gid = g.perms.getOwner(name)
+		if mode, ok := g.perms.getMode(name, g.perms.fileModes); ok {
+			entry.mode = mode
+		}
+	}
+
+	var err error
+	switch entryType {
+	case EntrySymlink:
+		entry.mode = os.ModeSymlink | 0777
+		entry.target, err = g.getSymlinkTarget(index, filepath.FromSlash(name), func(target string) (string, error) {
+			return "/" + target, nil // archive root is used as root of absolute links
+		})
+		entry.target = filepath.ToSlash(entry.target)
+		if err != nil {
+			return err
+		}
+		return g.archive.Add(entry, nil)
+	case EntryHardlink:
+		targetIndex, ok := g.special.GetTarget(index)
+		if ok == false {
+			break // there is no file to link to
+		}
+		target, err := g.getPath(targetIndex)
+		if err != nil {
+			return err
+		}
+		entry.hardlink = true
+		entry.target = filepath.ToSlash(target)
+		return g.archive.Add(entry, nil)
+	case EntryFifo:
+		entry.mode |= os.ModeNamedPipe
+		return g.archive.Add(entry, nil)
+	case EntryEmpty:
+		entry.size = 0
+	}
+
+	return g.archive.Add(entry, func(w io.Writer) error {
+		gen, close, err := g.getFileGenerator(name)
+		if err != nil {
+			return err
+		}
+		defer close()
+		err = copyData(ctx, w, entry.size, GetFileHeader(path.Ext(name)), gen)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to write '%s'", name)
+		}
+		return nil
+	})
+}
+
+func (g *archiveFilesGenerator) generate(ctx context.Context, filesGenerated *uint64) error {
+	for i := uint(0); i < g.dirsCount; i++ {
+		dirName, err := g.dirNames.GetName(i)
+		if err != nil {
+			return errors.Wrap(err, "Failed to generate directory name")
+		}
+		dirName = filepath.ToSlash(dirName)
+		err = g.addDir(dirName)
+		if err != nil {
+			return errors.Wrap(err, "Failed to add directory")
+		}
+		for j := uint(0); j < g.filesCount; j++ {
+			if isStopped(ctx) {
+				return ErrCanceled
+			}
+			fileName, err := g.fileNames.GetName(j)
+			if err != nil {
+				return errors.Wrap(err, "Failed to generate file name")
+			}
+			err = g.addEntry(ctx, i*g.filesCount+j, path.Join(dirName, fileName))
+			if err != nil {
+				return errors.Wrap(err, "Failed to add file")
+			}
+			atomic.AddUint64(filesGenerated, 1)
+		}
+	}
+	return g.archive.Close()
+}
+
+func (g *archiveFilesGenerator) Generate() error {
+	return g.GenerateContext(context.Background())
+}
+
+// GenerateContext writes archive. Archive is not completed if generation is failed or canceled
+func (g *archiveFilesGenerator) GenerateContext(parent context.Context) error {
+	ctx, cancel := stopContext(parent, g.stop)
+	defer cancel()
+
+	filesGenerated := uint64(0)
+	err := g.runWithProgress(func() error {
+		return g.generate(ctx, &filesGenerated)
+	}, func() Progress {
+		return Progress{
+			Processed: atomic.LoadUint64(&filesGenerated),
+			Total:     uint64(g.dirsCount * g.filesCount),
+		}
+	})
+	if err != nil {
+		return errors.Wrap(getCanceledError(parent, err), "Failed to generate archive")
+	}
+	return nil
+}
+
+// CreateArchiveFileGenerator creates generator of the same tree as CreateLinearFileGenerator
+// does but it is written to writer as archive of ArchiveEnum format. Checkpoint and attributes
+// are not supported. Writer is not closed by generator
+func CreateArchiveFileGenerator(gen DataGenerator, writer io.Writer, format int, dirsCount uint,
+	filesCount uint, fileSize uint64, opts ...Option) (FilesGenerator, error) {
+	linear, err := createLinearFileGenerator(componentArchive, gen, "", dirsCount, filesCount, fileSize, opts)
+	if err != nil {
+		return nil, err
+	}
+	if format == ArchiveZip && linear.special != nil &&
+		(linear.special.GetRatio(EntryHardlink) > 0 || linear.special.GetRatio(EntryFifo) > 0) {
+		return nil, errors.Wrap(ErrNotSupported, "Zip format has no hard links and named pipes")
+	}
+
+	archive, err := createArchiveWriter(writer, format)
+	if err != nil {
+		return nil, err
+	}
+	return &archiveFilesGenerator{
+		linearFilesGenerator: linear,
+		archive:              archive,
+		dirs:                 make(map[string]bool),
+	}, nil
+}
@@ -0,0 +1,182 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Tests for generation of archives
+*/
+
+package fglib
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readTar returns entries of tar archive in format of readTree. Hard links are replaced with data of targets
+func readTar(t *testing.T, data []byte, compressed bool) map[string]string {
+	var reader io.Reader = bytes.NewReader(data)
+	if compressed {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = gzipReader
+	}
+	archive := tar.NewReader(reader)
+	tree := make(map[string]string)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			tree[header.Name] = ""
+		case tar.TypeSymlink:
+			tree[header.Name] = "-> " + header.Linkname
+		case tar.TypeLink:
+			tree[header.Name] = tree[header.Linkname]
+		case tar.TypeFifo:
+			tree[header.Name] = header.FileInfo().Mode().Type().String()
+		default:
+			content, err := io.ReadAll(archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tree[header.Name] = string(content)
+		}
+	}
+	return tree
+}
+
+// readZip returns entries of zip archive in format of readTree
+func readZip(t *testing.T, data []byte) map[string]string {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := make(map[string]string)
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if file.Mode()&os.ModeSymlink != 0 {
+			tree[file.Name] = "-> " + string(content)
+		} else {
+			tree[file.Name] = string(content)
+		}
+	}
+	return tree
+}
+
+// TestArchiveContents checks that archive has the same files as tree generated with the same options
+func TestArchiveContents(t *testing.T) {
+	const dirs, files, fileSize = 3, 10, 3000
+	kinds := []int{SymlinkRelative, SymlinkAbsolute, SymlinkDangling}
+	tests := []struct {
+		format  int
+		special []float64 // symlinks, hardlinks, fifos, empty
+	}{
+		{ArchiveTar, nil},
+		{ArchiveTar, []float64{0.2, 0.2, 0.1, 0.1}},
+		{ArchiveTarGz, []float64{0.2, 0.2, 0.1, 0.1}},
+		{ArchiveZip, nil},
+		{ArchiveZip, []float64{0.3, 0, 0, 0.2}},
+	}
+	for _, test := range tests {
+		opts := []Option{WithDirNames(CreateNestedNameGenerator(CreatePrefixNameGenerator("dir_"), 2))}
+		if test.special != nil {
+			special, err := CreateSpecialFiles(SeedFromUint64(1), test.special[0], test.special[1], test.special[2],
+				test.special[3], kinds)
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts = append(opts, WithSpecialFiles(special))
+		}
+
+		root := t.TempDir()
+		gen, err := CreatePseudoRandomDataGenerator(SeedFromUint64(2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		generateTree(t, gen, root, dirs, files, fileSize, opts...)
+		expected := make(map[string]string)
+		for name, data := range readTree(t, root) {
+			/* absolute symlinks in archive are relative to its root */
+			expected[name] = strings.Replace(data, "-> "+filepath.ToSlash(root), "-> ", 1)
+		}
+		delete(expected, "./")
+
+		gen, err = CreatePseudoRandomDataGenerator(SeedFromUint64(2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var archive bytes.Buffer
+		g, err := CreateArchiveFileGenerator(gen, &archive, test.format, dirs, files, fileSize, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = g.Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var tree map[string]string
+		if test.format == ArchiveZip {
+			tree = readZip(t, archive.Bytes())
+		} else {
+			tree = readTar(t, archive.Bytes(), test.format == ArchiveTarGz)
+		}
+		if reflect.DeepEqual(tree, expected) == false {
+			for name, data := range expected {
+				if len(data) > 40 {
+					data = data[:40]
+				}
+				if tree[name] != expected[name] {
+					t.Errorf("Entry '%s' of archive %d with special files %v differs from '%s'", name, test.format,
+						test.special, data)
+				}
+			}
+			t.Errorf("Archive has %d entries, tree has %d", len(tree), len(expected))
+		}
+	}
+}
+
+func TestArchiveErrors(t *testing.T) {
+	special, err := CreateSpecialFiles(SeedFromUint64(1), 0, 0.1, 0, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		format int
+		opts   []Option
+	}{
+		{ArchiveZip, []Option{WithSpecialFiles(special)}},
+		{ArchiveTar, []Option{WithCheckpoint(CreateCheckpoint("", GeneratorPseudo, nil, "", 1, 1, 1))}},
+		{ArchiveTar, []Option{WithAttributes(&Attributes{})}},
+		{100, nil},
+	}
+	for _, test := range tests {
+		_, err = CreateArchiveFileGenerator(CreateNullDataGenerator(), io.Discard, test.format, 1, 1, 1, test.opts...)
+		if err == nil {
+			t.Errorf("Generator of archive %d is created with unsupported options", test.format)
+		}
+	}
+}
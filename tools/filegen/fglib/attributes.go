@@ -0,0 +1,229 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Extended attributes and ACL generation
+*/
+
+package fglib
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/* POSIX ACL serialization as it is stored in system.posix_acl_access attribute */
+
+const (
+	aclVersion  = 2
+	aclUserObj  = 0x01
+	aclUser     = 0x02
+	aclGroupObj = 0x04
+	aclGroup    = 0x08
+	aclMask     = 0x10
+	aclOther    = 0x20
+
+	aclXattrName = "system.posix_acl_access"
+)
+
+type aclEntry struct {
+	tag  uint16
+	perm uint16
+	id   uint32
+}
+
+func (e aclEntry) String() string {
+	perm := []byte("---")
+	for i, c := range "rwx" {
+		if e.perm&(4>>uint(i)) != 0 {
+			perm[i] = byte(c)
+		}
+	}
+
+	switch e.tag {
+	case aclUserObj:
+		return "user::" + string(perm)
+	case aclUser:
+		return fmt.Sprintf("user:%d:%s", e.id, perm)
+	case aclGroupObj:
+		return "group::" + string(perm)
+	case aclGroup:
+		return fmt.Sprintf("group:%d:%s", e.id, perm)
+	case aclMask:
+		return "mask::" + string(perm)
+	}
+	return "other::" + string(perm)
+}
+
+// encodeACL returns ACL in binary format and text format. Entries must be sorted by tag and id
+func encodeACL(entries []aclEntry) ([]byte, string) {
+	data := make([]byte, 4, 4+8*len(entries))
+	binary.LittleEndian.PutUint32(data, aclVersion)
+	text := make([]string, 0, len(entries))
+	for _, e := range entries {
+		entry := make([]byte, 8)
+		binary.LittleEndian.PutUint16(entry, e.tag)
+		binary.LittleEndian.PutUint16(entry[2:], e.perm)
+		binary.LittleEndian.PutUint32(entry[4:], e.id)
+		data = append(data, entry...)
+		text = append(text, e.String())
+	}
+	return data, strings.Join(text, ",")
+}
+
+/* Attributes generator */
+
+// Attributes sets random extended attributes and ACL entries to files. Attributes depend on
+// seed and relative path only. Applied attributes are added to manifest if it is set.
+type Attributes struct {
+	seed       []byte
+	xattrs     int
+	valueSize  int
+	aclEntries int
+	manifest   *Manifest
+}
+
+func (a *Attributes) getACL(random []byte, mode os.FileMode) []aclEntry {
+	perm := uint16(mode.Perm())
+	entries := []aclEntry{{tag: aclUserObj, perm: perm >> 6 & 7}}
+	mask := uint16(0)
+
+	/* named entries with ids from 10000 to 60000 sorted by id as it is required */
+	users, groups := []aclEntry{}, []aclEntry{}
+	for i := 0; i < a.aclEntries; i++ {
+		v := binary.LittleEndian.Uint32(random[i*4:])
+		e := aclEntry{perm: uint16(v & 7), id: 10000 + (v>>3)%50000}
+		mask |= e.perm
+		if v&(1<<31) == 0 {
+			e.tag = aclUser
+			users = appendACLEntry(users, e)
+		} else {
+			e.tag = aclGroup
+			groups = appendACLEntry(groups, e)
+		}
+	}
+	entries = append(entries, users...)
+	entries = append(entries, aclEntry{tag: aclGroupObj, perm: perm >> 3 & 7})
+	entries = append(entries, groups...)
+	if len(users)+len(groups) > 0 {
+		entries = append(entries, aclEntry{tag: aclMask, perm: mask | perm>>3&7})
+	}
+	return append(entries, aclEntry{tag: aclOther, perm: perm & 7})
+}
+
+// appendACLEntry inserts entry keeping order by id. Entry with the same id is skipped
+func appendACLEntry(entries []aclEntry, e aclEntry) []aclEntry {
+	i := 0
+	for i < len(entries) && entries[i].id < e.id {
+		i++
+	}
+	if i < len(entries) && entries[i].id == e.id {
+		return entries
+	}
+	entries = append(entries, aclEntry{})
+	copy(entries[i+1:], entries[i:])
+	entries[i] = e
+	return entries
+}
+
+// getXattrs returns names and values of extended attributes for seed of file
+func (a *Attributes) getXattrs(seed []byte) (map[string][]byte, error) {
+	names, err := CreateRandomNameGenerator(seed, 12, NameCharsAlnum)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create attributes names generator")
+	}
+	xattrs := make(map[string][]byte)
+	for i := 0; i < a.xattrs; i++ {
+		attrName, err := names.GetName(uint(i))
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to generate attribute name")
+		}
+		xattrs["user."+attrName] = getIndexRandom(seed, uint(i), a.valueSize)
+	}
+	return xattrs, nil
+}
+
+func (a *Attributes) getSeed(name string) []byte {
+	return append(append([]byte{}, a.seed...), name...)
+}
+
+// ApplyXattrs sets extended attributes to file or directory with path. It should be called before
+// mode is changed because read only mode denies setting of user attributes
+func (a *Attributes) ApplyXattrs(path string, name string) error {
+	if a.xattrs == 0 {
+		return nil
+	}
+	xattrs, err := a.getXattrs(a.getSeed(name))
+	if err != nil {
+		return err
+	}
+	for attrName, value := range xattrs {
+		err = setXattr(path, attrName, value)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to set attribute '%s' to '%s'", attrName, path)
+		}
+	}
+	return nil
+}
+
+// ApplyACL sets ACL to file or directory with path and adds its attributes to manifest. It should
+// be called after mode is changed because chmod rewrites owner, mask and other ACL entries
+func (a *Attributes) ApplyACL(path string, name string) error {
+	seed := a.getSeed(name)
+	entry := ManifestEntry{Path: name}
+	if a.xattrs > 0 {
+		xattrs, err := a.getXattrs(seed)
+		if err != nil {
+			return err
+		}
+		entry.Xattrs = xattrs
+	}
+
+	if a.aclEntries > 0 {
+		info, err := os.Stat(path)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to get mode of '%s'", path)
+		}
+		random := getIndexRandom(seed, uint(a.xattrs), 4*a.aclEntries)
+		data, text := encodeACL(a.getACL(random, info.Mode()))
+		err = setXattr(path, aclXattrName, data)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to set ACL '%s' to '%s'", text, path)
+		}
+		entry.ACL = text
+	}
+
+	if a.manifest != nil {
+		return a.manifest.Add(entry)
+	}
+	return nil
+}
+
+// Apply sets attributes and ACL to file or directory with path. Name is relative path to select
+// attributes
+func (a *Attributes) Apply(path string, name string) error {
+	err := a.ApplyXattrs(path, name)
+	if err != nil {
+		return err
+	}
+	return a.ApplyACL(path, name)
+}
+
+// CreateAttributes creates generator of xattrs count with values of valueSize bytes and
+// ACL with aclEntries named users and groups. Manifest could be nil
+func CreateAttributes(seed []byte, xattrs int, valueSize int, aclEntries int, manifest *Manifest) (*Attributes, error) {
+	if xattrs < 0 || valueSize < 0 || aclEntries < 0 {
+		return nil, fmt.Errorf("Attributes count and size cannot be negative")
+	}
+	return &Attributes{
+		seed:       seed,
+		xattrs:     xattrs,
+		valueSize:  valueSize,
+		aclEntries: aclEntries,
+		manifest:   manifest,
+	}, nil
+}
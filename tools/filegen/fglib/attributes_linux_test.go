@@ -0,0 +1,125 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Tests for extended attributes, ACL and manifest of them
+*/
+
+package fglib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestEncodeACL(t *testing.T) {
+	tests := []struct {
+		entries []aclEntry
+		text    string
+	}{
+		{[]aclEntry{{tag: aclUserObj, perm: 6}, {tag: aclGroupObj, perm: 4}, {tag: aclOther, perm: 0}},
+			"user::rw-,group::r--,other::---"},
+		{[]aclEntry{{tag: aclUserObj, perm: 7}, {tag: aclUser, perm: 5, id: 10001}, {tag: aclGroupObj, perm: 1},
+			{tag: aclGroup, perm: 2, id: 20000}, {tag: aclMask, perm: 7}, {tag: aclOther, perm: 4}},
+			"user::rwx,user:10001:r-x,group::--x,group:20000:-w-,mask::rwx,other::r--"},
+	}
+	for _, test := range tests {
+		data, text := encodeACL(test.entries)
+		if text != test.text {
+			t.Errorf("ACL is encoded to '%s' instead of '%s'", text, test.text)
+		}
+		if decoded := decodeACL(t, data); decoded != test.text {
+			t.Errorf("Binary ACL is decoded to '%s' instead of '%s'", decoded, test.text)
+		}
+	}
+}
+
+// decodeACL returns text form of ACL in binary format
+func decodeACL(t *testing.T, data []byte) string {
+	if len(data) < 4 || (len(data)-4)%8 != 0 || binary.LittleEndian.Uint32(data) != aclVersion {
+		t.Fatalf("Invalid binary ACL %v", data)
+	}
+	text := []string{}
+	for i := 4; i < len(data); i += 8 {
+		text = append(text, aclEntry{
+			tag:  binary.LittleEndian.Uint16(data[i:]),
+			perm: binary.LittleEndian.Uint16(data[i+2:]),
+			id:   binary.LittleEndian.Uint32(data[i+4:]),
+		}.String())
+	}
+	return strings.Join(text, ",")
+}
+
+func getXattr(t *testing.T, path string, name string) []byte {
+	data := make([]byte, 64*1024)
+	n, err := syscall.Getxattr(path, name, data)
+	if err != nil {
+		t.Fatalf("Failed to get attribute '%s' of '%s': %v", name, path, err)
+	}
+	return data[:n]
+}
+
+// TestAttributesManifest checks that manifest describes attributes and ACL of files on disk including
+// files with changed modes
+func TestAttributesManifest(t *testing.T) {
+	perms, err := CreatePermissions(SeedFromUint64(2), []uint32{0644, 0600, 0640, 0444}, []uint32{0755, 0750},
+		IDRange{Min: -1}, IDRange{Min: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		xattrs     int
+		aclEntries int
+		opts       []Option
+	}{
+		{3, 0, nil},
+		{0, 2, nil},
+		{2, 3, nil},
+		{2, 3, []Option{WithPermissions(perms)}},
+	}
+	for _, test := range tests {
+		root := t.TempDir()
+		entries := generateAttributes(t, root, test.xattrs, test.aclEntries, test.opts...)
+		if len(entries) != 2*5+2 {
+			t.Errorf("Manifest has %d entries instead of %d", len(entries), 2*5+2)
+		}
+		for _, entry := range entries {
+			path := filepath.Join(root, filepath.FromSlash(entry.Path))
+			if len(entry.Xattrs) != test.xattrs {
+				t.Errorf("Manifest has %d attributes of '%s' instead of %d", len(entry.Xattrs), entry.Path,
+					test.xattrs)
+			}
+			for name, value := range entry.Xattrs {
+				if strings.HasPrefix(name, "user.") == false || len(value) != 32 {
+					t.Errorf("Attribute '%s' of '%s' has %d bytes", name, entry.Path, len(value))
+				}
+				if bytes.Equal(getXattr(t, path, name), value) == false {
+					t.Errorf("Attribute '%s' of '%s' differs from manifest", name, entry.Path)
+				}
+			}
+			if test.aclEntries == 0 {
+				if entry.ACL != "" {
+					t.Errorf("Manifest has ACL '%s' of '%s'", entry.ACL, entry.Path)
+				}
+				continue
+			}
+			if acl := decodeACL(t, getXattr(t, path, aclXattrName)); acl != entry.ACL {
+				t.Errorf("ACL of '%s' is '%s' instead of '%s' from manifest", entry.Path, acl, entry.ACL)
+			}
+		}
+	}
+}
+
+// TestAttributesReproducible checks that attributes depend on seed and path only
+func TestAttributesReproducible(t *testing.T) {
+	first := generateAttributes(t, t.TempDir(), 2, 2)
+	second := generateAttributes(t, t.TempDir(), 2, 2)
+	if reflect.DeepEqual(first, second) == false {
+		t.Errorf("Attributes of trees generated with the same seed differ")
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Throughput measurement of data generators
+*/
+
+package fglib
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type BenchResult struct {
+	Bytes    uint64 // count of generated bytes
+	Duration time.Duration
+}
+
+// Throughput returns generated gigabytes (10^9 bytes) per second
+func (r *BenchResult) Throughput() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) / r.Duration.Seconds() / 1e9
+}
+
+type GeneratorBench interface {
+	// Run reads data from generator by blocks for duration
+	Run(gen DataGenerator) (*BenchResult, error)
+	// RunContext stops measurement when context is done and returns error of context
+	RunContext(ctx context.Context, gen DataGenerator) (*BenchResult, error)
+	Stop() // stops measurement. Run returns ErrCanceled
+}
+
+type generatorBench struct {
+	options
+	duration  time.Duration
+	blockSize int
+
+	read uint64 // atomic counter of generated bytes
+
+	stop     chan bool
+	stopOnce sync.Once
+}
+
+func (b *generatorBench) Stop() {
+	b.stopOnce.Do(func() {
+		close(b.stop)
+	})
+}
+
+func (b *generatorBench) bench(ctx context.Context, gen DataGenerator, result *BenchResult) error {
+	block := make([]byte, b.blockSize)
+	deadline := time.Now().Add(b.duration)
+	start := time.Now()
+	defer func() {
+		result.Duration = time.Since(start)
+	}()
+
+	for time.Now().Before(deadline) {
+		if isStopped(ctx) {
+			return ErrCanceled
+		}
+		n, err := gen.Read(block)
+		result.Bytes += uint64(n)
+		atomic.AddUint64(&b.read, uint64(n))
+		if err != nil {
+			return errors.Wrap(err, "Failed to read data from generator")
+		}
+	}
+	return nil
+}
+
+func (b *generatorBench) Run(gen DataGenerator) (*BenchResult, error) {
+	return b.RunContext(context.Background(), gen)
+}
+
+func (b *generatorBench) RunContext(parent context.Context, gen DataGenerator) (*BenchResult, error) {
+	ctx, cancel := stopContext(parent, b.stop)
+	defer cancel()
+
+	atomic.StoreUint64(&b.read, 0)
+	result := &BenchResult{}
+	err := b.runWithProgress(func() error {
+		return b.bench(ctx, gen, result)
+	}, func() Progress {
+		return Progress{Processed: atomic.LoadUint64(&b.read)}
+	})
+	if err != nil {
+		return result, errors.Wrap(getCanceledError(parent, err), "Failed to measure generator")
+	}
+	return result, nil
+}
+
+// CreateGeneratorBench creates measurement of generators throughput. Data is read by blocks of
+// blockSize during duration
+func CreateGeneratorBench(duration time.Duration, blockSize int, opts ...Option) (GeneratorBench, error) {
+	if duration <= 0 {
+		return nil, fmt.Errorf("Duration must be positive")
+	}
+	if blockSize <= 0 {
+		return nil, fmt.Errorf("Block size must be positive")
+	}
+	options, err := getOptions(componentBench, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &generatorBench{
+		options:   options,
+		duration:  duration,
+		blockSize: blockSize,
+		stop:      make(chan bool),
+	}, nil
+}
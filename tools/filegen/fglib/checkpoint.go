@@ -0,0 +1,108 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Checkpoint of files generation to resume it after interruption
+*/
+
+package fglib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// Checkpoint stores progress of files generation. Dir and File point to the first file
+// that is not completed yet, so it is the file right after the last completed one.
+type Checkpoint struct {
+	Generator int    `json:"generator"` // GeneratorEnum
+	Seed      []byte `json:"seed"`      // seed of pseudo random generator
+	Layout    string `json:"layout"`    // hash of options of names, types, attributes and modes of files
+	Dirs      uint   `json:"dirs"`
+	Files     uint   `json:"files"`
+	FileSize  uint64 `json:"file_size"`
+	Dir       uint   `json:"dir"`
+	File      uint   `json:"file"`
+
+	path string
+}
+
+func (c *Checkpoint) Save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "Failed to serialize checkpoint")
+	}
+
+	/* write to temporary file and rename to keep checkpoint consistent on crash */
+	tmpPath := c.path + ".tmp"
+	err = ioutil.WriteFile(tmpPath, data, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to write checkpoint '%s'", tmpPath)
+	}
+	err = os.Rename(tmpPath, c.path)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to rename checkpoint to '%s'", c.path)
+	}
+	return nil
+}
+
+func (c *Checkpoint) Remove() error {
+	err := os.Remove(c.path)
+	if err != nil && os.IsNotExist(err) == false {
+		return errors.Wrapf(err, "Failed to remove checkpoint '%s'", c.path)
+	}
+	return nil
+}
+
+// Check returns error if checkpoint was created for other generation parameters
+func (c *Checkpoint) Check(generator int, layout string, dirs, files uint, fileSize uint64) error {
+	if c.Generator != generator {
+		return fmt.Errorf("Checkpoint was created with other generator type")
+	}
+	if c.Layout != layout {
+		return fmt.Errorf("Checkpoint was created with other options of names, special files, attributes or permissions")
+	}
+	if c.Dirs != dirs || c.Files != files || c.FileSize != fileSize {
+		return fmt.Errorf("Checkpoint was created for %d dirs with %d files of %d bytes",
+			c.Dirs, c.Files, c.FileSize)
+	}
+	return nil
+}
+
+func CreateCheckpoint(path string, generator int, seed []byte, layout string, dirs, files uint,
+	fileSize uint64) *Checkpoint {
+	return &Checkpoint{
+		Generator: generator,
+		Seed:      seed,
+		Layout:    layout,
+		Dirs:      dirs,
+		Files:     files,
+		FileSize:  fileSize,
+		path:      path,
+	}
+}
+
+// LoadCheckpoint returns nil checkpoint without error if checkpoint file does not exist
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "Failed to read checkpoint '%s'", path)
+	}
+
+	c := &Checkpoint{path: path}
+	err = json.Unmarshal(data, c)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse checkpoint '%s'", path)
+	}
+	if c.Dir > c.Dirs || c.File >= c.Files && c.Files > 0 {
+		return nil, fmt.Errorf("Invalid position (%d, %d) in checkpoint '%s'", c.Dir, c.File, path)
+	}
+	return c, nil
+}
@@ -0,0 +1,189 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Tests for checkpoint and resuming of files generation
+*/
+
+package fglib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestResumeGeneration checks that resumed tree is the same as tree generated without interruption
+func TestResumeGeneration(t *testing.T) {
+	const dirs, files, fileSize = 3, 4, 5000
+	tests := []struct {
+		generator int
+		create    func(seed []byte) (DataGenerator, error)
+		dir       uint
+		file      uint
+	}{
+		{GeneratorPseudo, CreatePseudoRandomDataGenerator, 0, 0},
+		{GeneratorPseudo, CreatePseudoRandomDataGenerator, 1, 2},
+		{GeneratorPseudo, CreatePseudoRandomDataGenerator, 2, 3},
+		{GeneratorXoshiro, CreateXoshiroDataGenerator, 1, 0},
+		{GeneratorChaCha8, CreateChaCha8DataGenerator, 0, 3},
+		{GeneratorAESCTR, CreateAESCTRDataGenerator, 2, 1},
+	}
+	for _, test := range tests {
+		root := t.TempDir()
+		seed := SeedFromUint64(11)
+		gen, err := test.create(seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := filepath.Join(root, "expected")
+		generateTree(t, gen, expected, dirs, files, fileSize)
+		expectedTree := readTree(t, expected)
+
+		/* the first files are completed before interruption, the file at checkpoint is written partially */
+		resumed := filepath.Join(root, "resumed")
+		generated := make(map[string]string)
+		for i := uint(0); i <= test.dir*files+test.file; i++ {
+			name := fmt.Sprintf("dir_%d/file_%d", i/files, i%files)
+			generated[name] = expectedTree[name]
+			if i == test.dir*files+test.file {
+				generated[name] = "partial"
+			}
+		}
+		writeTreeFiles(t, resumed, generated)
+
+		checkpointPath := filepath.Join(root, "checkpoint")
+		checkpoint := CreateCheckpoint(checkpointPath, test.generator, seed, "", dirs, files, fileSize)
+		checkpoint.Dir, checkpoint.File = test.dir, test.file
+		err = checkpoint.Save()
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := LoadCheckpoint(checkpointPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reflect.DeepEqual(loaded, checkpoint) == false {
+			t.Fatalf("Loaded checkpoint %+v differs from saved one %+v", loaded, checkpoint)
+		}
+
+		/* generator is created with seed of checkpoint as it is done on resume */
+		gen, err = test.create(loaded.Seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		generateTree(t, gen, resumed, dirs, files, fileSize, WithCheckpoint(loaded))
+		if reflect.DeepEqual(readTree(t, resumed), expectedTree) == false {
+			t.Errorf("Tree resumed at (%d, %d) with generator %s differs from generated one",
+				test.dir, test.file, GetGeneratorName(test.generator))
+		}
+		if _, err = os.Stat(checkpointPath); os.IsNotExist(err) == false {
+			t.Errorf("Checkpoint is not removed after generation: %v", err)
+		}
+	}
+}
+
+func TestCheckpointCheck(t *testing.T) {
+	checkpoint := CreateCheckpoint("", GeneratorPseudo, SeedFromUint64(1), "layout", 3, 4, 5000)
+	tests := []struct {
+		generator int
+		layout    string
+		dirs      uint
+		files     uint
+		fileSize  uint64
+		valid     bool
+	}{
+		{GeneratorPseudo, "layout", 3, 4, 5000, true},
+		{GeneratorXoshiro, "layout", 3, 4, 5000, false},
+		{GeneratorPseudo, "other", 3, 4, 5000, false},
+		{GeneratorPseudo, "layout", 4, 4, 5000, false},
+		{GeneratorPseudo, "layout", 3, 5, 5000, false},
+		{GeneratorPseudo, "layout", 3, 4, 5001, false},
+	}
+	for _, test := range tests {
+		err := checkpoint.Check(test.generator, test.layout, test.dirs, test.files, test.fileSize)
+		if (err == nil) != test.valid {
+			t.Errorf("Check of generator %d with layout '%s', %d dirs, %d files of %d bytes returned %v",
+				test.generator, test.layout, test.dirs, test.files, test.fileSize, err)
+		}
+	}
+}
+
+// TestResumeLayout checks that generation is not resumed if options of names, types, attributes or
+// modes of files differ from options stored in checkpoint
+func TestResumeLayout(t *testing.T) {
+	getOptions := func() *CmdOptions {
+		o := &CmdOptions{GeneratorType: GeneratorPseudo, Seed: SeedFromUint64(1)}
+		o.Generate.Folders, o.Generate.Files, o.Generate.FileSize = 3, 4, 5000
+		o.Names.DirPrefix, o.Names.FilePrefix = "dir_", "file_"
+		return o
+	}
+	types, err := ParseFileTypesMix(".jpg:1,.txt:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherTypes, err := ParseFileTypesMix(".jpg:1,.txt:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		change func(o *CmdOptions)
+		valid  bool
+	}{
+		{func(o *CmdOptions) {}, true},
+		{func(o *CmdOptions) { o.Attributes.Manifest = "manifest" }, true},
+		{func(o *CmdOptions) { o.Names.FileType = NamesRandom }, false},
+		{func(o *CmdOptions) { o.Names.DirPrefix = "d" }, false},
+		{func(o *CmdOptions) { o.Names.FileTypes = otherTypes }, false},
+		{func(o *CmdOptions) { o.Generate.Symlinks = 0.1 }, false},
+		{func(o *CmdOptions) { o.Generate.SymlinkKinds = []int{SymlinkLoop} }, false},
+		{func(o *CmdOptions) { o.Attributes.Xattrs = 2 }, false},
+		{func(o *CmdOptions) { o.Permissions.FileModes = []uint32{0600} }, false},
+		{func(o *CmdOptions) { o.Permissions.Uids = IDRange{Min: 1000, Max: 1001} }, false},
+	}
+	for i, test := range tests {
+		created := getOptions()
+		created.Names.FileTypes = types
+		o := getOptions()
+		o.Names.FileTypes, _ = ParseFileTypesMix(".jpg:1,.txt:1")
+		test.change(o)
+		o.Generate.ResumeFrom = CreateCheckpoint("", created.GeneratorType, created.Seed, created.GetLayoutHash(),
+			created.Generate.Folders, created.Generate.Files, created.Generate.FileSize)
+		err := o.processResume()
+		if (err == nil) != test.valid {
+			t.Errorf("Resume with change %d returned %v", i, err)
+		}
+	}
+}
+
+func TestLoadCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	checkpoint, err := LoadCheckpoint(filepath.Join(dir, "missing"))
+	if checkpoint != nil || err != nil {
+		t.Errorf("Missing checkpoint is loaded as %+v, %v", checkpoint, err)
+	}
+
+	tests := []struct {
+		data  string
+		valid bool
+	}{
+		{`{"dirs":3,"files":4,"dir":1,"file":3}`, true},
+		{`{"dirs":3,"files":4,"dir":3,"file":0}`, true},
+		{`{"dirs":3,"files":4,"dir":4,"file":0}`, false},
+		{`{"dirs":3,"files":4,"dir":1,"file":4}`, false},
+		{`{"dirs":3`, false},
+	}
+	for _, test := range tests {
+		path := filepath.Join(dir, "checkpoint")
+		err = os.WriteFile(path, []byte(test.data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = LoadCheckpoint(path)
+		if (err == nil) != test.valid {
+			t.Errorf("Load of checkpoint '%s' returned %v", test.data, err)
+		}
+	}
+}
@@ -8,19 +8,38 @@ Brief:     Command-line options parser and logic
 package fglib
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go2c/optparse"
+	"github.com/pkg/errors"
 )
 
 // CommandEnum
 const (
 	CommandGenerate = iota
 	CommandChange
+	CommandStream
+	CommandRead
+	CommandWorkload
+	CommandDiff
+	CommandBench
+)
+
+// NamesEnum
+const (
+	NamesIndex = iota
+	NamesRandom
+	NamesUUID
 )
 
 // GeneratorEnum
@@ -28,6 +47,32 @@ const (
 	GeneratorCrypto = iota
 	GeneratorPseudo
 	GeneratorNull
+	GeneratorXoshiro
+	GeneratorChaCha8
+	GeneratorAESCTR
+	GeneratorsCount
+)
+
+var generatorNames = []string{"crypto", "pseudo", "null", "xoshiro", "chacha8", "aes-ctr"}
+
+func GetGeneratorName(genType int) string {
+	return generatorNames[genType]
+}
+
+// IsSeededGenerator returns true if generator gives the same data for the same seed
+func IsSeededGenerator(genType int) bool {
+	switch genType {
+	case GeneratorPseudo, GeneratorXoshiro, GeneratorChaCha8, GeneratorAESCTR:
+		return true
+	}
+	return false
+}
+
+const Version = "0.1.0"
+
+var (
+	ErrHelp    = fmt.Errorf("Help is requested")
+	ErrVersion = fmt.Errorf("Version is requested")
 )
 
 type CmdOptions struct {
@@ -35,139 +80,850 @@ type CmdOptions struct {
 	Path          string // Root path got processing files
 	GeneratorType int    // GeneratorEnum
 	Seed          []byte
-	Generate      struct {
+	Skew          *Skew // Distribution of changes among files for change and workload commands
+
+	Progress         int // ProgressEnum
+	ProgressInterval time.Duration
+
+	Generate struct {
 		Folders  uint   // Folders tree count. For example [3,4] - 3 folders in root, 4 folders in previous, etc.
 		Files    uint   // Files count in each tree level
 		FileSize uint64 // File size for each tree level
+
+		Output  string // Path to archive or '-' for stdout. Files are written to Path if empty
+		Archive int    // ArchiveEnum. Used if Output is set
+
+		Checkpoint string      // Path to checkpoint file to store progress
+		Resume     bool        // Resume generation from checkpoint if true
+		ResumeFrom *Checkpoint // Loaded checkpoint to resume from
+
+		Symlinks     float64 // Ratio of symlinks
+		Hardlinks    float64 // Ratio of hardlinks to generated files
+		Fifos        float64 // Ratio of named pipes
+		Empty        float64 // Ratio of empty files
+		SymlinkKinds []int   // SymlinkEnum
+	}
+	Names struct {
+		DirType      int // NamesEnum
+		DirTypeName  string
+		FileType     int // NamesEnum
+		FileTypeName string
+		DirPrefix    string
+		FilePrefix   string
+		FileSuffix   string
+		FileExt      string
+		FileTypes    *FileTypesMix // Extensions of files with weights. Not used if nil
+		Length       int           // Length of random names in bytes. Maximum length if 0
+		Chars        string        // Characters of random names
+		Depth        uint          // Depth of directories
+	}
+	Permissions struct {
+		FileModes []uint32 // Random mode from list is set. Not changed if empty
+		DirModes  []uint32
+		Uids      IDRange
+		Gids      IDRange
+	}
+	Attributes struct {
+		Xattrs     uint   // Count of extended attributes
+		ValueSize  uint64 // Size of extended attributes values
+		ACLEntries uint   // Count of named entries in ACL
+		Manifest   string // Path to manifest to store attributes in
+	}
+	Stream struct {
+		Size uint64 // Size of stream. Stream is infinite if 0
+	}
+	Read struct {
+		Access      int    // ReadEnum
+		BlockSize   uint64 // Size of each read
+		Concurrency uint   // Count of files read in parallel
+		Verify      bool   // Compare data with data generated by pseudo generator with seed
+	}
+	Workload struct {
+		Duration time.Duration  // Duration of workload
+		Mix      *OperationsMix // Operations with weights
+		FileSize uint64         // Size of created files
+	}
+	Diff struct {
+		PathA string // Tree before changes
+		PathB string // Tree after changes
+		JSON  bool   // Report differences in JSON lines
+	}
+	Bench struct {
+		Duration time.Duration // Duration of measurement of each generator
 	}
 	Change struct {
-		Ratio    float64  // Change ratio
-		Interval Interval // Interval to change files
-		Once     bool     // Use once if true otherwise until the end of file
-		Reverse  bool     // Change file from end if true
+		Ratio    float64    // Change ratio
+		Count    uint       // Count of files to change. Ratio is used if 0
+		Filter   FileFilter // Filter of files to change
+		Journal  string     // Path to journal to store written ranges in
+		Interval Interval   // Interval to change files
+		Once     bool       // Use once if true otherwise until the end of file
+		Reverse  bool       // Change file from end if true
 	}
 }
 
-var Options CmdOptions
-
-func processFileSize(rawSize string) {
+func (o *CmdOptions) processFileSize(rawSize string) error {
 	var err error
-	Options.Generate.FileSize, err = ParseSize(rawSize)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
-	}
+	o.Generate.FileSize, err = ParseSize(rawSize)
+	return err
 }
 
-func processInterval(interval string) {
+func (o *CmdOptions) processInterval(interval string) error {
 	if interval == "" {
-		Options.Change.Interval = GetFullInterval()
+		o.Change.Interval = GetFullInterval()
 	} else {
 		var err error
-		Options.Change.Interval, err = ParseInterval(interval)
+		o.Change.Interval, err = ParseInterval(interval)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
+			return err
 		}
 	}
+	return nil
 }
-func processCommonCommand() {
+
+func (o *CmdOptions) processCommonCommand() error {
 	/* Check options */
 
-	if len(Options.Path) == 0 {
-		fmt.Fprintf(os.Stderr, "Error: path is not set. Use the --path option.\n")
-		usage(os.Stderr)
-		os.Exit(1)
+	if len(o.Path) == 0 {
+		return fmt.Errorf("Path is not set. Use the --path option")
+	}
+	return nil
+}
+
+func (o *CmdOptions) processGenerateCommand() error {
+	if o.Generate.Folders == 0 {
+		return fmt.Errorf("Use the --dirs option to set directories count to generate")
+	}
+
+	if o.Generate.Files == 0 {
+		return fmt.Errorf("Use the --files option to set files count to generate")
+	}
+
+	if o.Generate.Resume {
+		if o.Generate.Checkpoint == "" {
+			return fmt.Errorf("Use the --checkpoint option to set checkpoint to resume from")
+		}
+
+		var err error
+		o.Generate.ResumeFrom, err = LoadCheckpoint(o.Generate.Checkpoint)
+		if err != nil {
+			return err
+		}
+		if o.Generate.ResumeFrom == nil {
+			log.Printf("Checkpoint '%s' is not found. Starting from the beginning\n", o.Generate.Checkpoint)
+		}
+	}
+	return nil
+}
+
+func (o *CmdOptions) processRead(access string, blockSize string, seed uint64) error {
+	switch access {
+	case "seq", "sequential":
+		o.Read.Access = ReadSequential
+	case "random":
+		o.Read.Access = ReadRandom
+	default:
+		return fmt.Errorf("Invalid access type '%s'", access)
+	}
+
+	var err error
+	o.Read.BlockSize, err = ParseSize(blockSize)
+	if err != nil {
+		return err
+	}
+	if o.Read.BlockSize == 0 || o.Read.BlockSize > 1024*1024*1024 {
+		return fmt.Errorf("Block size must be in [1;1G]")
+	}
+	if o.Read.Concurrency == 0 {
+		return fmt.Errorf("Concurrency must be positive")
+	}
+	if o.Read.Verify && (o.GeneratorType != GeneratorPseudo || seed == 0) {
+		return fmt.Errorf("Verification requires pseudo generator with seed. Use -g pseudo --seed options")
+	}
+	return nil
+}
+
+func (o *CmdOptions) processChange(skew string) error {
+	var err error
+	o.Skew, err = ParseSkew(skew)
+	if err != nil {
+		return err
+	}
+
+	if o.Change.Ratio < 0 || o.Change.Ratio > 1 {
+		return fmt.Errorf("Change ratio must be in [0;1]")
+	}
+	if o.Change.Count > 0 && o.Change.Ratio != 1 {
+		return fmt.Errorf("--scale and --count options cannot be used together")
+	}
+	return nil
+}
+
+func (o *CmdOptions) processFilter(include, exclude, includeRegexp, excludeRegexp, exts, minSize, maxSize,
+	minAge, maxAge string, minDepth, maxDepth uint) error {
+	filter := &o.Change.Filter
+	splitList := func(list string) []string {
+		if list == "" {
+			return nil
+		}
+		return strings.Split(list, ",")
+	}
+	filter.Include = splitList(include)
+	filter.Exclude = splitList(exclude)
+	err := filter.CheckGlobs()
+	if err != nil {
+		return err
+	}
+
+	compile := func(expr string) (*regexp.Regexp, error) {
+		if expr == "" {
+			return nil, nil
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid regular expression '%s'", expr)
+		}
+		return re, nil
+	}
+	filter.IncludeRegexp, err = compile(includeRegexp)
+	if err != nil {
+		return err
+	}
+	filter.ExcludeRegexp, err = compile(excludeRegexp)
+	if err != nil {
+		return err
+	}
+
+	for _, ext := range splitList(exts) {
+		if strings.HasPrefix(ext, ".") == false {
+			ext = "." + ext
+		}
+		filter.Extensions = append(filter.Extensions, ext)
+	}
+
+	for _, size := range []struct {
+		value string
+		dest  *int64
+	}{{minSize, &filter.MinSize}, {maxSize, &filter.MaxSize}} {
+		if size.value == "" {
+			continue
+		}
+		value, err := ParseSize(size.value)
+		if err != nil {
+			return err
+		}
+		*size.dest = int64(value)
+	}
+
+	for _, age := range []struct {
+		value string
+		dest  *time.Duration
+	}{{minAge, &filter.MinAge}, {maxAge, &filter.MaxAge}} {
+		if age.value == "" {
+			continue
+		}
+		*age.dest, err = time.ParseDuration(age.value)
+		if err != nil || *age.dest < 0 {
+			return fmt.Errorf("Invalid age '%s'", age.value)
+		}
+	}
+
+	filter.Now = time.Now()
+	filter.MinDepth = int(minDepth)
+	filter.MaxDepth = int(maxDepth)
+	if (filter.MaxSize > 0 && filter.MinSize > filter.MaxSize) ||
+		(filter.MaxAge > 0 && filter.MinAge > filter.MaxAge) ||
+		(filter.MaxDepth > 0 && filter.MinDepth > filter.MaxDepth) {
+		return fmt.Errorf("Minimal values of filter cannot be more than maximal ones")
+	}
+	return nil
+}
+
+func (o *CmdOptions) processWorkload(duration string, mix string) error {
+	if o.Command != CommandWorkload {
+		return nil
+	}
+
+	if duration == "" {
+		duration = "1m"
+	}
+	var err error
+	o.Workload.Duration, err = time.ParseDuration(duration)
+	if err != nil || o.Workload.Duration <= 0 {
+		return fmt.Errorf("Invalid workload duration '%s'", duration)
+	}
+	o.Workload.Mix, err = ParseOperationsMix(mix)
+	if err != nil {
+		return err
+	}
+	o.Workload.FileSize = o.Generate.FileSize
+	if o.Workload.FileSize == 0 {
+		o.Workload.FileSize = 64 * 1024
+	}
+	return nil
+}
+
+func (o *CmdOptions) processBench(duration string) error {
+	if o.Command != CommandBench {
+		return nil
+	}
+	if duration == "" {
+		duration = "3s"
+	}
+	var err error
+	o.Bench.Duration, err = time.ParseDuration(duration)
+	if err != nil || o.Bench.Duration <= 0 {
+		return fmt.Errorf("Invalid bench duration '%s'", duration)
+	}
+	return nil
+}
+
+func (o *CmdOptions) processFormat(format string) error {
+	switch format {
+	case "dir":
+		if o.Generate.Output != "" {
+			return fmt.Errorf("Use the --format option to set archive format")
+		}
+		return nil
+	case "tar":
+		o.Generate.Archive = ArchiveTar
+	case "tar.gz", "tgz":
+		o.Generate.Archive = ArchiveTarGz
+	case "zip":
+		o.Generate.Archive = ArchiveZip
+	default:
+		return fmt.Errorf("Invalid format '%s'", format)
+	}
+
+	if o.Generate.Output == "" {
+		o.Generate.Output = "-"
+	}
+	if o.Generate.Checkpoint != "" {
+		return fmt.Errorf("Checkpoint cannot be used with archives")
+	}
+	if o.Attributes.Xattrs > 0 || o.Attributes.ACLEntries > 0 {
+		return fmt.Errorf("Extended attributes and ACL cannot be used with archives")
+	}
+	if o.Generate.Archive == ArchiveZip && (o.Generate.Hardlinks > 0 || o.Generate.Fifos > 0) {
+		return fmt.Errorf("Zip format has no hard links and named pipes")
+	}
+	return nil
+}
+
+func (o *CmdOptions) processSpecialFiles(symlinkKinds string) error {
+	kinds := map[string]int{
+		"relative": SymlinkRelative,
+		"absolute": SymlinkAbsolute,
+		"dangling": SymlinkDangling,
+		"loop":     SymlinkLoop,
+	}
+	for _, name := range strings.Split(symlinkKinds, ",") {
+		kind, ok := kinds[name]
+		if ok == false {
+			return fmt.Errorf("Invalid symlink kind '%s'", name)
+		}
+		o.Generate.SymlinkKinds = append(o.Generate.SymlinkKinds, kind)
+	}
+
+	sum := o.Generate.Symlinks + o.Generate.Hardlinks + o.Generate.Fifos + o.Generate.Empty
+	for _, ratio := range []float64{o.Generate.Symlinks, o.Generate.Hardlinks,
+		o.Generate.Fifos, o.Generate.Empty} {
+		if ratio < 0 || ratio > 1 {
+			sum = 2 // invalid
+		}
 	}
+	if sum > 1 {
+		return fmt.Errorf("Ratios of links and special files must be in [0;1] with sum not more than 1")
+	}
+	return nil
 }
 
-func processGenerateCommand() {
-	if Options.Generate.Folders == 0 {
-		fmt.Fprintf(os.Stderr, "Error: Use the --folders option to set files count to generate.\n")
-		usage(os.Stderr)
-		os.Exit(1)
+func (o *CmdOptions) processAttributes(valueSize string) error {
+	var err error
+	o.Attributes.ValueSize, err = ParseSize(valueSize)
+	if err != nil {
+		return err
+	}
+	if o.Attributes.ValueSize > 64*1024 {
+		return fmt.Errorf("Size of extended attributes values cannot be more than 64K")
+	}
+	return nil
+}
+
+func (o *CmdOptions) processPermissions(fileMode, dirMode, uid, gid string) error {
+	parseModes := func(mode string, randomModes []uint32) ([]uint32, error) {
+		if mode == "" {
+			return nil, nil
+		}
+		if mode == "random" {
+			return randomModes, nil
+		}
+		value, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil || value > 07777 {
+			return nil, fmt.Errorf("Invalid mode '%s'. Must be octal value or 'random'", mode)
+		}
+		return []uint32{uint32(value)}, nil
+	}
+	var err error
+	o.Permissions.FileModes, err = parseModes(fileMode, RandomFileModes)
+	if err != nil {
+		return err
+	}
+	o.Permissions.DirModes, err = parseModes(dirMode, RandomDirModes)
+	if err != nil {
+		return err
 	}
 
-	if Options.Generate.Files == 0 {
-		fmt.Fprintf(os.Stderr, "Error: Use the --files option to set files count to generate.\n")
-		usage(os.Stderr)
-		os.Exit(1)
+	parseIDs := func(ids string) (IDRange, error) {
+		if ids == "" {
+			return IDRange{Min: -1, Max: -1}, nil
+		}
+		if os.Geteuid() != 0 {
+			return IDRange{}, fmt.Errorf("Owner of files can be changed by root only")
+		}
+		parts := strings.Split(ids, "-")
+		min, err := strconv.Atoi(parts[0])
+		max := min
+		if err == nil && len(parts) == 2 {
+			max, err = strconv.Atoi(parts[1])
+		}
+		if err != nil || len(parts) > 2 || min < 0 || max < min {
+			return IDRange{}, fmt.Errorf("Invalid id '%s'. Must be 'id' or 'min-max' range", ids)
+		}
+		return IDRange{Min: min, Max: max}, nil
 	}
+	o.Permissions.Uids, err = parseIDs(uid)
+	if err != nil {
+		return err
+	}
+	o.Permissions.Gids, err = parseIDs(gid)
+	return err
 }
 
-func processGeneratorType(genType string, seed uint64) {
-	if genType == "crypto" {
-		Options.GeneratorType = GeneratorCrypto
-		if seed != 0 {
-			fmt.Fprintf(os.Stderr, "Warning: seed is not used with crypto generator.\n")
+func (o *CmdOptions) processResume() error {
+	checkpoint := o.Generate.ResumeFrom
+	if checkpoint == nil {
+		return nil
+	}
+
+	err := checkpoint.Check(o.GeneratorType, o.GetLayoutHash(), o.Generate.Folders, o.Generate.Files,
+		o.Generate.FileSize)
+	if err != nil {
+		return errors.Wrap(err, "Failed to resume")
+	}
+	if bytes.Equal(checkpoint.Seed, o.Seed) == false {
+		return fmt.Errorf("Failed to resume: seed differs from seed in checkpoint")
+	}
+	log.Printf("Resuming from directory %d, file %d\n", checkpoint.Dir, checkpoint.File)
+	return nil
+}
+
+// GetLayoutHash returns hash of options of names, special files, attributes and permissions. Files
+// generated with the same hash and seed have the same names, types, attributes and modes
+func (o *CmdOptions) GetLayoutHash() string {
+	hash := sha256.New()
+	names := o.Names
+	names.FileTypes = nil
+	fmt.Fprintf(hash, "%+v\n", names)
+	if o.Names.FileTypes != nil {
+		fmt.Fprintf(hash, "%+v\n", *o.Names.FileTypes)
+	}
+	fmt.Fprintf(hash, "%v %v %v %v %v\n", o.Generate.Symlinks, o.Generate.Hardlinks, o.Generate.Fifos,
+		o.Generate.Empty, o.Generate.SymlinkKinds)
+	attrs := o.Attributes
+	attrs.Manifest = ""
+	fmt.Fprintf(hash, "%+v\n%+v\n", attrs, o.Permissions)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (o *CmdOptions) processGeneratorType(genType string, seed uint64) error {
+	o.GeneratorType = -1
+	for i, name := range generatorNames {
+		if genType == name {
+			o.GeneratorType = i
 		}
-	} else if genType == "pseudo" {
-		Options.GeneratorType = GeneratorPseudo
+	}
+	if o.GeneratorType < 0 {
+		return fmt.Errorf("Invalid generator type '%s'", genType)
+	}
+
+	if seed == 0 && o.Generate.ResumeFrom != nil {
+		o.Seed = o.Generate.ResumeFrom.Seed
+		if o.Seed != nil {
+			log.Printf("Using seed from checkpoint\n")
+		}
+		return nil
+	}
+
+	if IsSeededGenerator(o.GeneratorType) {
 		if seed == 0 {
 			seed = uint64(time.Now().UnixNano())
 		}
-		Options.Seed = SeedFromUint64(seed)
+		o.Seed = SeedFromUint64(seed)
 		log.Printf("Using seed: %d\n", seed)
-	} else if genType == "null" {
-		Options.GeneratorType = GeneratorNull
-		if seed != 0 {
-			fmt.Fprintf(os.Stderr, "Warning: seed is not used with null generator.\n")
+	} else if seed != 0 {
+		/* seed is used for random names and special files selection */
+		o.Seed = SeedFromUint64(seed)
+		fmt.Fprintf(os.Stderr, "Warning: seed is used only for names and files layout with %s generator.\n", genType)
+	}
+	return nil
+}
+
+func (o *CmdOptions) processNames(nameLength string, fileTypes string) error {
+	if o.Names.Depth == 0 {
+		return fmt.Errorf("Directories depth must be positive")
+	}
+
+	if o.Names.FileExt != "" && strings.HasPrefix(o.Names.FileExt, ".") == false {
+		o.Names.FileExt = "." + o.Names.FileExt
+	}
+
+	if fileTypes != "" {
+		if o.Names.FileExt != "" {
+			return fmt.Errorf("--ext and --types options cannot be used together")
 		}
+		var err error
+		o.Names.FileTypes, err = ParseFileTypesMix(fileTypes)
+		if err != nil {
+			return err
+		}
+	}
+
+	if nameLength == "max" {
+		o.Names.Length = 0
 	} else {
-		fmt.Fprintf(os.Stderr, "Error: invalid generator type '%s'.\n", genType)
-		usage(os.Stderr)
-		os.Exit(1)
+		var err error
+		o.Names.Length, err = strconv.Atoi(nameLength)
+		if err != nil || o.Names.Length <= 0 || o.Names.Length > NameMax {
+			return fmt.Errorf("Invalid name length '%s'. Must be in [1;%d] or 'max'", nameLength, NameMax)
+		}
+	}
+
+	switch o.Names.Chars {
+	case "alnum":
+		o.Names.Chars = NameCharsAlnum
+	case "unicode":
+		o.Names.Chars = NameCharsUnicode
+	case "special":
+		o.Names.Chars = NameCharsSpecial
+	default:
+		return fmt.Errorf("Invalid name characters set '%s'", o.Names.Chars)
+	}
+
+	processNamesType := func(namesType string) (int, error) {
+		switch namesType {
+		case "index":
+			return NamesIndex, nil
+		case "random":
+			return NamesRandom, nil
+		case "uuid":
+			return NamesUUID, nil
+		}
+		return 0, fmt.Errorf("Invalid names type '%s'", namesType)
+	}
+	var err error
+	o.Names.DirType, err = processNamesType(o.Names.DirTypeName)
+	if err != nil {
+		return err
+	}
+	o.Names.FileType, err = processNamesType(o.Names.FileTypeName)
+	if err != nil {
+		return err
+	}
+
+	for _, names := range []struct {
+		namesType int
+		dirs      bool
+		kind      string
+	}{{o.Names.DirType, true, "directories"}, {o.Names.FileType, false, "files"}} {
+		affix := o.GetNameAffixLength(names.dirs)
+		length := 0
+		switch names.namesType {
+		case NamesRandom:
+			length = o.Names.Length
+			if length == 0 {
+				length = 1 // maximal length is got from affix
+			}
+		case NamesUUID:
+			length = 36
+		}
+		if length+affix > NameMax {
+			return fmt.Errorf("Names of %s with prefix and suffix of %d bytes are longer than %d bytes",
+				names.kind, affix, NameMax)
+		}
+	}
+
+	/* random names are different in each run without seed as data is */
+	if o.Command == CommandGenerate && o.Seed == nil &&
+		(o.Names.DirType != NamesIndex || o.Names.FileType != NamesIndex) {
+		seed := uint64(time.Now().UnixNano())
+		o.Seed = SeedFromUint64(seed)
+		log.Printf("Using seed for names: %d\n", seed)
+	}
+	return nil
+}
+
+// GetNameAffixLength returns length in bytes of prefix and suffix of directories or files names.
+// The longest extension of files types is counted
+func (o *CmdOptions) GetNameAffixLength(dirs bool) int {
+	if dirs {
+		return len(o.Names.DirPrefix)
 	}
+	length := len(o.Names.FilePrefix) + len(o.Names.FileSuffix) + len(o.Names.FileExt)
+	if o.Names.FileTypes != nil {
+		length += o.Names.FileTypes.GetMaxExtLength()
+	}
+	return length
+}
+
+func (o *CmdOptions) processProgress(progress string, interval string) error {
+	switch progress {
+	case "auto":
+		o.Progress = ProgressPlain
+		if isTerminal(o.GetProgressOutput()) {
+			o.Progress = ProgressTTY
+		}
+	case "tty":
+		o.Progress = ProgressTTY
+	case "plain":
+		o.Progress = ProgressPlain
+	case "json":
+		o.Progress = ProgressJSON
+	case "none":
+		o.Progress = ProgressSilent
+	default:
+		return fmt.Errorf("Invalid progress type '%s'", progress)
+	}
+
+	var err error
+	o.ProgressInterval, err = time.ParseDuration(interval)
+	if err != nil || o.ProgressInterval <= 0 {
+		return fmt.Errorf("Invalid progress interval '%s'", interval)
+	}
+	return nil
 }
 
-func processCommand(cmd string) {
+// GetProgressOutput returns stdout or stderr if data, archive or differences are written to stdout
+func (o *CmdOptions) GetProgressOutput() *os.File {
+	if o.Command == CommandStream || o.Command == CommandDiff || (o.Command == CommandGenerate && o.Generate.Output == "-") {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+func (o *CmdOptions) processCommand(cmd string, args []string) error {
 	if cmd == "gen" || cmd == "generate" {
-		Options.Command = CommandGenerate
-		processCommonCommand()
-		processGenerateCommand()
+		o.Command = CommandGenerate
+		if o.Generate.Output == "" {
+			err := o.processCommonCommand()
+			if err != nil {
+				return err
+			}
+		}
+		return o.processGenerateCommand()
 	} else if cmd == "chg" || cmd == "change" {
-		Options.Command = CommandChange
-		processCommonCommand()
-	} else {
-		fmt.Fprintf(os.Stderr, "Error: Invalid command '%s'\n", cmd)
-		usage(os.Stderr)
-		os.Exit(1)
+		o.Command = CommandChange
+		return o.processCommonCommand()
+	} else if cmd == "read" {
+		o.Command = CommandRead
+		return o.processCommonCommand()
+	} else if cmd == "workload" {
+		o.Command = CommandWorkload
+		return o.processCommonCommand()
+	} else if cmd == "diff" {
+		o.Command = CommandDiff
+		if len(args) != 2 {
+			return fmt.Errorf("Set two trees to compare")
+		}
+		o.Diff.PathA, o.Diff.PathB = args[0], args[1]
+		return nil
+	} else if cmd == "bench" {
+		o.Command = CommandBench
+		return nil
+	} else if cmd == "stream" {
+		o.Command = CommandStream
+		o.Stream.Size = o.Generate.FileSize
+		return nil
 	}
+	return fmt.Errorf("Invalid command '%s'", cmd)
 }
 
-func usage(f io.Writer) {
+// Usage prints command-line usage
+func Usage(f io.Writer) {
 	fmt.Fprintln(f, "Usage:")
 	fmt.Fprintf(f, "  %s [command] [options]\n\n", os.Args[0])
 
 	fmt.Fprintln(f, "Commands:")
 	fmt.Fprintln(f, "  gen, generate              Generate files")
 	fmt.Fprintln(f, "  chg, change                Change files")
+	fmt.Fprintln(f, "  stream                     Write generated data to stdout")
+	fmt.Fprintln(f, "  read                       Read files and report throughput and latency")
+	fmt.Fprintln(f, "  workload                   Run mix of operations over files for duration and report statistics")
+	fmt.Fprintln(f, "  diff A B                   Report files and ranges of data which differ in trees A and B")
+	fmt.Fprintln(f, "  bench                      Measure throughput of data generators on this machine")
 	fmt.Fprintln(f)
 
 	fmt.Fprintln(f, "Common options:")
-	fmt.Fprintln(f, "  -p, --path                 Path to processing folder")
+	fmt.Fprintln(f, "  -p, --path                 Path to processing folder. For change command it could be a block device")
+	fmt.Fprintln(f, "                             or a regular file used as device image")
+	fmt.Fprintln(f, "  --progress                 Type of progress reporting. Default is auto. Progress bar and plain lines")
+	fmt.Fprintln(f, "                             are written to stderr if data is written to stdout")
+	fmt.Fprintln(f, "     auto                    Progress bar if stdout is terminal, plain lines otherwise")
+	fmt.Fprintln(f, "     tty                     Progress bar on the same line of stdout")
+	fmt.Fprintln(f, "     plain                   Log line with progress on stdout for each interval")
+	fmt.Fprintln(f, "     json                    JSON event with progress on stderr for each interval")
+	fmt.Fprintln(f, "     none                    Progress is not reported")
+	fmt.Fprintln(f, "  --progress-interval        Interval of progress reporting. For example: 500ms, 10s. Default is 1s")
+	fmt.Fprintln(f)
+
+	fmt.Fprintln(f, "Sizes:")
+	fmt.Fprintln(f, "  Number with optional fraction and suffix rounded down to bytes. For example: 512, 4K, 1.5G, 10MiB")
+	fmt.Fprintln(f, "     k, m, g, t, p           Decimal units: 10^3, 10^6, 10^9, 10^12, 10^15 bytes")
+	fmt.Fprintln(f, "     K, M, G, T, P           Binary units: 2^10, 2^20, 2^30, 2^40, 2^50 bytes")
+	fmt.Fprintln(f, "     kB, MB, GB, TB, PB      Decimal units in any case")
+	fmt.Fprintln(f, "     KiB, MiB, GiB, TiB, PiB Binary units in any case")
+	fmt.Fprintln(f, "     B                       Bytes")
 	fmt.Fprintln(f)
 
 	fmt.Fprintln(f, "Generate command options:")
 	fmt.Fprintln(f, "  -d, --dirs                 Directories count to generate")
 	fmt.Fprintln(f, "  -f, --files                Files count to generate")
-	fmt.Fprintln(f, "  -s, --size                 File size to generate. Size format: [\\d{k,K,m,M,g,G}]")
+	fmt.Fprintln(f, "  -s, --size                 File size to generate. Size format: see Sizes")
+	fmt.Fprintln(f, "  --format                   Format of generated files. Default is dir")
+	fmt.Fprintln(f, "     dir                     Files are written to directory set by --path option")
+	fmt.Fprintln(f, "     tar                     Tar archive")
+	fmt.Fprintln(f, "     tar.gz, tgz             Tar archive compressed with gzip")
+	fmt.Fprintln(f, "     zip                     Zip archive without hard links and named pipes")
+	fmt.Fprintln(f, "  -o, --output               Path to archive or '-' to write archive to stdout. Default is stdout")
+	fmt.Fprintln(f, "  --checkpoint               Path to file to store generation progress in")
+	fmt.Fprintln(f, "  --resume                   Resume generation from checkpoint set by --checkpoint option")
+	fmt.Fprintln(f, "  --symlinks                 Ratio of symbolic links instead of files. Range: [0;1]. By default is 0")
+	fmt.Fprintln(f, "  --symlink-kinds            Kinds of symbolic links. By default all kinds are used")
+	fmt.Fprintln(f, "     relative                Relative link to generated file")
+	fmt.Fprintln(f, "     absolute                Absolute link to generated file")
+	fmt.Fprintln(f, "     dangling                Link to not existing file")
+	fmt.Fprintln(f, "     loop                    Link to parent directory")
+	fmt.Fprintln(f, "  --hardlinks                Ratio of hard links to generated files. Range: [0;1]. By default is 0")
+	fmt.Fprintln(f, "  --fifos                    Ratio of named pipes. Range: [0;1]. By default is 0")
+	fmt.Fprintln(f, "  --empty                    Ratio of empty files. Range: [0;1]. By default is 0")
+	fmt.Fprintln(f)
+
+	fmt.Fprintln(f, "Names options:")
+	fmt.Fprintln(f, "  --dir-names                Type of directories names. Default is index")
+	fmt.Fprintln(f, "  --file-names               Type of files names. Default is index")
+	fmt.Fprintln(f, "     index                   Index of directory or file in decimal")
+	fmt.Fprintln(f, "     random                  Random names. Names differ in each run. Use --seed option to get the same names")
+	fmt.Fprintln(f, "     uuid                    Random UUIDs. Names differ in each run. Use --seed option to get the same names")
+	fmt.Fprintln(f, "  --dir-prefix               Prefix of directories names. Default is 'dir_'")
+	fmt.Fprintln(f, "  --file-prefix              Prefix of files names. Default is 'file_'")
+	fmt.Fprintln(f, "  --file-suffix              Suffix of files names")
+	fmt.Fprintln(f, "  --ext                      Extension of files names")
+	fmt.Fprintln(f, "  --types                    Mix of files types with weights. Format: ['.ext:weight{%}',*].")
+	fmt.Fprintln(f, "                             For example: '.jpg:20%,.txt:50%,.bin:30%'")
+	fmt.Fprintln(f, "  --name-length              Length of random names in bytes or 'max' to get names of 255 bytes. Default is 16")
+	fmt.Fprintln(f, "                             Length with prefix, suffix and extension cannot be more than 255 bytes")
+	fmt.Fprintln(f, "  --name-chars               Characters of random names")
+	fmt.Fprintln(f, "     alnum                   Latin letters and digits. Used by default")
+	fmt.Fprintln(f, "     unicode                 Letters of different languages and emoji")
+	fmt.Fprintln(f, "     special                 Latin letters, digits, spaces and special characters")
+	fmt.Fprintln(f, "  --dir-depth                Depth of each generated directory to get long paths. Default is 1")
+	fmt.Fprintln(f)
+
+	fmt.Fprintln(f, "Permissions options:")
+	fmt.Fprintln(f, "  --file-mode                Mode of files in octal format or 'random' to set random modes")
+	fmt.Fprintln(f, "                             including setuid bits, read-only and not readable files")
+	fmt.Fprintln(f, "  --dir-mode                 Mode of directories in octal format or 'random' to set random modes")
+	fmt.Fprintln(f, "                             including sticky bit, read-only and not readable directories")
+	fmt.Fprintln(f, "  --uid                      User id of files and directories or range of random ids 'min-max'. Root only")
+	fmt.Fprintln(f, "  --gid                      Group id of files and directories or range of random ids 'min-max'. Root only")
+	fmt.Fprintln(f)
+
+	fmt.Fprintln(f, "Attributes options:")
+	fmt.Fprintln(f, "  --xattrs                   Count of random user.* extended attributes for each file and directory")
+	fmt.Fprintln(f, "  --xattr-size               Size of extended attributes values. Size format: see Sizes. By default is 32")
+	fmt.Fprintln(f, "  --acl                      Count of random named users and groups in ACL for each file and directory")
+	fmt.Fprintln(f, "  --manifest                 Path to file to store set attributes in")
 	fmt.Fprintln(f)
 
 	fmt.Fprintln(f, "Change command options:")
 	fmt.Fprintln(f, "  --scale                    Percent of files cont to change. Range: [0;1]. By default is equal to 1")
+	fmt.Fprintln(f, "  --count                    Count of files to change. Used instead of --scale option")
+	fmt.Fprintln(f, "                             Files are selected uniformly. The same files are selected for the same --seed")
+	fmt.Fprintln(f, "  --skew                     Distribution of changes among files. Used for workload command too. Default is uniform")
+	fmt.Fprintln(f, "     uniform                 Each file has the same probability to be selected")
+	fmt.Fprintln(f, "     zipf{:exponent}         Zipfian distribution with exponent more than 1. Default exponent is 1.2")
+	fmt.Fprintln(f, "     hotset{:ops/files}      Percent of operations on percent of hot files. Default is 80/20")
+	fmt.Fprintln(f, "                             The same files are hot for the same --seed")
+	fmt.Fprintln(f, "  --include                  Glob patterns of files to change separated by commas. Pattern is matched with")
+	fmt.Fprintln(f, "                             relative path, name and parent directories of file. For example: 'dir_3*,*.txt'")
+	fmt.Fprintln(f, "  --exclude                  Glob patterns of files not to change separated by commas")
+	fmt.Fprintln(f, "  --include-regex            Regular expression of relative paths of files to change")
+	fmt.Fprintln(f, "  --exclude-regex            Regular expression of relative paths of files not to change")
+	fmt.Fprintln(f, "  --extensions               Extensions of files to change separated by commas. For example: 'jpg,txt'")
+	fmt.Fprintln(f, "  --min-size, --max-size     Range of sizes of files to change. Size format: see Sizes")
+	fmt.Fprintln(f, "  --min-age, --max-age       Range of time since modification of files to change. Age is computed at start")
+	fmt.Fprintln(f, "                             of command. For example: 10m, 24h")
+	fmt.Fprintln(f, "  --min-depth, --max-depth   Range of depth of files to change. Files in root have depth 1")
 	fmt.Fprintln(f, "  -i, --interval             Interval to change file with. Format: ['data not to change', 'data to change',{'data not to change'}].")
-	fmt.Fprintln(f, "                             Data format: [\\d{%, k,K,m,M,g,G}]. By default is [0,100%] and used until file ending")
+	fmt.Fprintln(f, "                             Data format: size or percents, for example 12.5%. The first value could be")
+	fmt.Fprintln(f, "                             negative to count it from the end of file. By default is [0,100%] and used until file ending")
 	fmt.Fprintln(f, "  --once                     Using of interval only once. Used only with -i, --interval option.")
+	fmt.Fprintln(f, "  --journal                  Path to file to store written ranges of files in")
 	fmt.Fprintln(f, "  --reverse                  Using interval from the file ending to begining. Used only with -i, --interval option.")
 	fmt.Fprintln(f)
 
+	fmt.Fprintln(f, "Stream command options:")
+	fmt.Fprintln(f, "  -s, --size                 Size of data to write. Size format: see Sizes. Infinite stream by default")
+	fmt.Fprintln(f)
+
+	fmt.Fprintln(f, "Read command options:")
+	fmt.Fprintln(f, "  --access                   Access to files data. Default is seq")
+	fmt.Fprintln(f, "     seq                     Files are read sequentially from the beginning to the end")
+	fmt.Fprintln(f, "     random                  Blocks are read at random offsets. Count of reads is the same")
+	fmt.Fprintln(f, "                             Offsets are the same for the same --seed and path of file")
+	fmt.Fprintln(f, "  --block-size               Size of each read. Size format: see Sizes. By default is 64K")
+	fmt.Fprintln(f, "  --concurrency              Count of files read in parallel. By default is 1")
+	fmt.Fprintln(f, "  --verify                   Compare data with files generated with the same --seed. Only files generated")
+	fmt.Fprintln(f, "                             with -g pseudo could be verified, so -g pseudo --seed N options are required")
+	fmt.Fprintln(f)
+
+	fmt.Fprintln(f, "Workload command options:")
+	fmt.Fprintln(f, "  --duration                 Duration of workload. For example: 30s, 30m, 2h. By default is 1m")
+	fmt.Fprintln(f, "  --mix                      Mix of operations with weights. Format: ['operation:weight{%}',*].")
+	fmt.Fprintln(f, "                             By default is 'create:10%,overwrite:15%,append:10%,read:40%,delete:5%,")
+	fmt.Fprintln(f, "                             rename:5%,stat:15%'")
+	fmt.Fprintln(f, "     create                  Create file of size set by -s, --size option. By default is 64K")
+	fmt.Fprintln(f, "     overwrite               Change file with interval set by -i, --interval, --once, --reverse options")
+	fmt.Fprintln(f, "     append                  Append block of size set by --block-size option")
+	fmt.Fprintln(f, "     read                    Read whole file by blocks of size set by --block-size option")
+	fmt.Fprintln(f, "     delete                  Delete file")
+	fmt.Fprintln(f, "     rename                  Rename file in the same directory")
+	fmt.Fprintln(f, "     stat                    Get file information")
+	fmt.Fprintln(f, "  --block-size               Size of appended blocks and reads. By default is 64K")
+	fmt.Fprintln(f, "  --concurrency              Count of operations run in parallel. By default is 1")
+	fmt.Fprintln(f, "                             Existing files are selected with --scale, --count and filters of change command")
+	fmt.Fprintln(f)
+
+	fmt.Fprintln(f, "Diff command options:")
+	fmt.Fprintln(f, "  --block-size               Granularity of differing ranges. By default is 64K")
+	fmt.Fprintln(f, "  --json                     Report each difference as JSON object on separate line")
+	fmt.Fprintln(f)
+
+	fmt.Fprintln(f, "Bench command options:")
+	fmt.Fprintln(f, "  --duration                 Duration of measurement of each generator. By default is 3s")
+	fmt.Fprintln(f, "  --block-size               Size of each read from generator. By default is 64K")
+	fmt.Fprintln(f)
+
 	fmt.Fprintln(f, "Generator options:")
 	fmt.Fprintln(f, "  -g, --generator            Type of generator to use")
 	fmt.Fprintln(f, "     crypto                  Crypto random data generator. Used by default.")
 	fmt.Fprintln(f, "     pseudo                  Pseudo random data generator")
 	fmt.Fprintln(f, "     null                    Null contains data generator")
-	fmt.Fprintln(f, "  --seed                     Initial seed for generated data. Can be used only with 'pseudo' generator")
+	fmt.Fprintln(f, "     xoshiro                 Fast xoshiro256** pseudo random data generator")
+	fmt.Fprintln(f, "     chacha8                 ChaCha8 pseudo random data generator")
+	fmt.Fprintln(f, "     aes-ctr                 AES-128 in counter mode. Uses AES-NI if CPU supports it")
+	fmt.Fprintln(f, "  --seed                     Initial seed for generated data. Can be used only with 'pseudo', 'xoshiro',")
+	fmt.Fprintln(f, "                             'chacha8' and 'aes-ctr' generators")
 	fmt.Fprintln(f)
 
 	fmt.Fprintln(f, "Auxillary options:")
@@ -175,22 +931,88 @@ func usage(f io.Writer) {
 	fmt.Fprintln(f, "  -v, --version              Print version and exit")
 }
 
-func ParseCmdOptions() *CmdOptions {
+// ParseCmdOptions parses command-line arguments. It returns ErrHelp or ErrVersion if help or
+// version is requested
+func ParseCmdOptions() (*CmdOptions, error) {
+	o := &CmdOptions{}
+
 	/* Initializing flags for parsing command-line arguments */
 
 	/* generate command options */
-	optparse.UintVar(&Options.Generate.Files, "files", 'f', 0)
-	optparse.UintVar(&Options.Generate.Folders, "dirs", 'd', 1)
+	optparse.UintVar(&o.Generate.Files, "files", 'f', 0)
+	optparse.UintVar(&o.Generate.Folders, "dirs", 'd', 1)
 	fileSize := optparse.String("size", 's', "0")
+	optparse.StringVar(&o.Generate.Output, "output", 'o', "")
+	format := optparse.String("format", 0, "dir")
+	optparse.StringVar(&o.Generate.Checkpoint, "checkpoint", 0, "")
+	optparse.BoolVar(&o.Generate.Resume, "resume", 0, false)
+	optparse.FloatVar(&o.Generate.Symlinks, "symlinks", 0, 0)
+	optparse.FloatVar(&o.Generate.Hardlinks, "hardlinks", 0, 0)
+	optparse.FloatVar(&o.Generate.Fifos, "fifos", 0, 0)
+	optparse.FloatVar(&o.Generate.Empty, "empty", 0, 0)
+	symlinkKinds := optparse.String("symlink-kinds", 0, "relative,absolute,dangling,loop")
+
+	/* names options */
+	optparse.StringVar(&o.Names.DirTypeName, "dir-names", 0, "index")
+	optparse.StringVar(&o.Names.FileTypeName, "file-names", 0, "index")
+	optparse.StringVar(&o.Names.DirPrefix, "dir-prefix", 0, "dir_")
+	optparse.StringVar(&o.Names.FilePrefix, "file-prefix", 0, "file_")
+	optparse.StringVar(&o.Names.FileSuffix, "file-suffix", 0, "")
+	optparse.StringVar(&o.Names.FileExt, "ext", 0, "")
+	optparse.StringVar(&o.Names.Chars, "name-chars", 0, "alnum")
+	optparse.UintVar(&o.Names.Depth, "dir-depth", 0, 1)
+	nameLength := optparse.String("name-length", 0, "16")
+	fileTypes := optparse.String("types", 0, "")
+
+	/* permissions options */
+	fileMode := optparse.String("file-mode", 0, "")
+	dirMode := optparse.String("dir-mode", 0, "")
+	uid := optparse.String("uid", 0, "")
+	gid := optparse.String("gid", 0, "")
+
+	/* attributes options */
+	optparse.UintVar(&o.Attributes.Xattrs, "xattrs", 0, 0)
+	optparse.UintVar(&o.Attributes.ACLEntries, "acl", 0, 0)
+	optparse.StringVar(&o.Attributes.Manifest, "manifest", 0, "")
+	xattrSize := optparse.String("xattr-size", 0, "32")
+
+	/* read command options */
+	access := optparse.String("access", 0, "seq")
+	blockSize := optparse.String("block-size", 0, "64K")
+	optparse.UintVar(&o.Read.Concurrency, "concurrency", 0, 1)
+	optparse.BoolVar(&o.Read.Verify, "verify", 0, false)
+
+	/* diff command options */
+	optparse.BoolVar(&o.Diff.JSON, "json", 0, false)
+
+	/* workload command options */
+	duration := optparse.String("duration", 0, "")
+	mix := optparse.String("mix", 0, "create:10%,overwrite:15%,append:10%,read:40%,delete:5%,rename:5%,stat:15%")
 
 	/* change command option */
-	optparse.FloatVar(&Options.Change.Ratio, "scale", 0, float64(1))
-	optparse.BoolVar(&Options.Change.Once, "once", 0, false)
-	optparse.BoolVar(&Options.Change.Reverse, "reverse", 0, false)
+	optparse.FloatVar(&o.Change.Ratio, "scale", 0, float64(1))
+	optparse.UintVar(&o.Change.Count, "count", 0, 0)
+	optparse.StringVar(&o.Change.Journal, "journal", 0, "")
+	skew := optparse.String("skew", 0, "uniform")
+	include := optparse.String("include", 0, "")
+	exclude := optparse.String("exclude", 0, "")
+	includeRegexp := optparse.String("include-regex", 0, "")
+	excludeRegexp := optparse.String("exclude-regex", 0, "")
+	exts := optparse.String("extensions", 0, "")
+	minSize := optparse.String("min-size", 0, "")
+	maxSize := optparse.String("max-size", 0, "")
+	minAge := optparse.String("min-age", 0, "")
+	maxAge := optparse.String("max-age", 0, "")
+	minDepth := optparse.Uint("min-depth", 0, 0)
+	maxDepth := optparse.Uint("max-depth", 0, 0)
+	optparse.BoolVar(&o.Change.Once, "once", 0, false)
+	optparse.BoolVar(&o.Change.Reverse, "reverse", 0, false)
 	interval := optparse.String("interval", 'i', "")
 
 	/* common options */
-	optparse.StringVar(&Options.Path, "path", 'p', "")
+	optparse.StringVar(&o.Path, "path", 'p', "")
+	progress := optparse.String("progress", 0, "auto")
+	progressInterval := optparse.String("progress-interval", 0, "1s")
 
 	/* generator options */
 	genType := optparse.String("generator", 'g', "crypto")
@@ -203,34 +1025,50 @@ func ParseCmdOptions() *CmdOptions {
 	/* Parsing command-line */
 	args, err := optparse.Parse()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%s: %s\n\n", os.Args[0], err.Error())
-		usage(os.Stderr)
-		os.Exit(1)
+		return nil, err
 	}
 
 	/* processing command line arguments */
 	if *help {
-		usage(os.Stdout)
-		os.Exit(0)
+		return nil, ErrHelp
 	}
 
 	if *version {
-		fmt.Println("Version: 0.1.0")
-		os.Exit(0)
+		return nil, ErrVersion
 	}
 
 	if len(args) == 0 {
-		fmt.Fprintf(os.Stderr, "Error: Set command to use\n")
-		usage(os.Stderr)
-		os.Exit(1)
+		return nil, fmt.Errorf("Set command to use")
 	}
 
 	cmd := args[0]
 
-	processFileSize(*fileSize)
-	processInterval(*interval)
-	processCommand(cmd)
-	processGeneratorType(*genType, uint64(*seed))
-
-	return &Options
+	processors := []func() error{
+		func() error { return o.processFileSize(*fileSize) },
+		func() error { return o.processInterval(*interval) },
+		func() error { return o.processSpecialFiles(*symlinkKinds) },
+		func() error { return o.processAttributes(*xattrSize) },
+		func() error { return o.processPermissions(*fileMode, *dirMode, *uid, *gid) },
+		func() error { return o.processFormat(*format) },
+		func() error { return o.processCommand(cmd, args[1:]) },
+		func() error { return o.processProgress(*progress, *progressInterval) },
+		func() error { return o.processGeneratorType(*genType, uint64(*seed)) },
+		func() error { return o.processNames(*nameLength, *fileTypes) },
+		func() error { return o.processRead(*access, *blockSize, uint64(*seed)) },
+		func() error { return o.processWorkload(*duration, *mix) },
+		func() error { return o.processBench(*duration) },
+		func() error { return o.processChange(*skew) },
+		func() error {
+			return o.processFilter(*include, *exclude, *includeRegexp, *excludeRegexp, *exts, *minSize, *maxSize,
+				*minAge, *maxAge, *minDepth, *maxDepth)
+		},
+		o.processResume,
+	}
+	for _, process := range processors {
+		err = process()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
 }
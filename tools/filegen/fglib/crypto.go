@@ -11,6 +11,7 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
 
@@ -53,11 +54,13 @@ func CreateCryptoDataGenerator() DataGenerator {
 /* Pseudo random data generator implementation */
 
 type pseudoRandomGenerator struct {
+	key  []byte // seed set by user, it is not changed by cloning
 	seed []byte
 
 	block   []byte
 	encrypt cipher.Block
 	index   int
+	rest    []byte // bytes of the last encrypted block not returned by previous read
 }
 
 func (gen *pseudoRandomGenerator) init() error {
@@ -68,6 +71,7 @@ func (gen *pseudoRandomGenerator) init() error {
 	}
 	gen.block = make([]byte, len(gen.seed))
 	copy(gen.block, gen.seed)
+	gen.rest = nil
 	return nil
 }
 
@@ -76,21 +80,23 @@ func (gen *pseudoRandomGenerator) Seed(key []byte) error {
 		return fmt.Errorf("Seed must have 16 bytes length. Got: %d", len(key))
 	}
 
+	gen.key = make([]byte, len(key))
+	copy(gen.key, key)
 	gen.seed = make([]byte, len(key))
 	copy(gen.seed, key)
 	return gen.init()
 }
 
+// Read returns the same data for the same seed independently of sizes of reads. Tail of the last
+// encrypted block is returned by the next read
 func (gen *pseudoRandomGenerator) Read(block []byte) (int, error) {
-	bitSize := len(gen.block)
-	bitsCount := len(block) / bitSize
-	if len(block)%bitSize > 0 {
-		bitsCount++
-	}
+	n := copy(block, gen.rest)
+	gen.rest = gen.rest[n:]
 
 	/* increment one ob bytes of encrypted block */
-	encrypted := make([]byte, len(gen.block))
-	for i := 0; i < bitsCount; i++ {
+	bitSize := len(gen.block)
+	encrypted := make([]byte, bitSize)
+	for n < len(block) {
 		gen.block[gen.index]++
 		gen.index++
 		if gen.index == bitSize {
@@ -98,11 +104,34 @@ func (gen *pseudoRandomGenerator) Read(block []byte) (int, error) {
 		}
 
 		gen.encrypt.Decrypt(encrypted, gen.block)
-		copy(block[bitSize*i:], encrypted)
+		copied := copy(block[n:], encrypted)
+		n += copied
+		if copied < bitSize {
+			gen.rest = encrypted[copied:]
+		}
 	}
 	return len(block), nil
 }
 
+// seek moves generator to offset from the beginning of data. It returns count of bytes to drop
+// from the beginning of the next read
+func (gen *pseudoRandomGenerator) seek(offset uint64) int {
+	gen.rest = nil
+	bitSize := uint64(len(gen.block))
+	count := offset / bitSize
+
+	/* each read of block increments the next byte of block in circle starting from the first one */
+	for i := range gen.block {
+		increments := count / bitSize
+		if uint64(i) < count%bitSize {
+			increments++
+		}
+		gen.block[i] = gen.seed[i] + byte(increments)
+	}
+	gen.index = int(count % bitSize)
+	return int(offset % bitSize)
+}
+
 func (gen *pseudoRandomGenerator) Close() error {
 	return nil
 }
@@ -114,6 +143,14 @@ func (gen *pseudoRandomGenerator) Clone() (DataGenerator, error) {
 	return clone, nil
 }
 
+// FileGenerator returns generator with seed depending on the file name only
+func (gen *pseudoRandomGenerator) FileGenerator(name string) (DataGenerator, error) {
+	hash := sha256.New()
+	hash.Write(gen.key)
+	hash.Write([]byte(name))
+	return CreatePseudoRandomDataGenerator(hash.Sum(nil)[:len(gen.key)])
+}
+
 func CreatePseudoRandomDataGenerator(seed []byte) (DataGenerator, error) {
 	gen := &pseudoRandomGenerator{}
 	err := gen.Seed(seed)
@@ -0,0 +1,28 @@
+//go:build linux
+// +build linux
+
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Size of block devices on Linux
+*/
+
+package fglib
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const blkGetSize64 = 0x80081272 // BLKGETSIZE64 ioctl
+
+func getDeviceSize(file *os.File) (int64, error) {
+	var size uint64
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), blkGetSize64, uintptr(unsafe.Pointer(&size)))
+	if errno != 0 {
+		return 0, errno
+	}
+	return int64(size), nil
+}
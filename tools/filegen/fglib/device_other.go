@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Size of block devices on systems without BLKGETSIZE64 ioctl
+*/
+
+package fglib
+
+import (
+	"io"
+	"os"
+)
+
+func getDeviceSize(file *os.File) (int64, error) {
+	return file.Seek(0, io.SeekEnd)
+}
@@ -13,4 +13,5 @@ import (
 
 var (
 	ErrNotSupported = fmt.Errorf("Not supported")
+	ErrCanceled     = fmt.Errorf("Canceled")
 )
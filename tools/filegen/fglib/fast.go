@@ -0,0 +1,228 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     High-throughput seedable pseudo random data generators
+*/
+
+package fglib
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"math/rand/v2"
+
+	"github.com/pkg/errors"
+)
+
+/* Seed of generators */
+
+// generatorSeed keeps seed of generator. Each clone gets the next seed as pseudo random
+// generator does, so clones generate different data
+type generatorSeed struct {
+	key  []byte // initial seed which is not changed by clones
+	seed []byte
+}
+
+func (s *generatorSeed) set(key []byte) error {
+	if len(key) != 16 {
+		return fmt.Errorf("Seed must have 16 bytes length. Got: %d", len(key))
+	}
+	s.key = make([]byte, len(key))
+	copy(s.key, key)
+	s.seed = make([]byte, len(key))
+	copy(s.seed, key)
+	return nil
+}
+
+// fileSeed returns seed depending on the initial seed and the file name only
+func (s *generatorSeed) fileSeed(name string) []byte {
+	hash := sha256.New()
+	hash.Write(s.key)
+	hash.Write([]byte(name))
+	return hash.Sum(nil)[:len(s.key)]
+}
+
+// cloneSeed returns seed for clone and changes seed for the next one
+func (s *generatorSeed) cloneSeed() []byte {
+	seed := make([]byte, len(s.seed))
+	copy(seed, s.seed)
+	s.seed[0]++
+	return seed
+}
+
+// expand returns 32 bytes of state got from seed
+func (s *generatorSeed) expand() [32]byte {
+	return sha256.Sum256(s.seed)
+}
+
+/* xoshiro256** data generator implementation */
+
+type xoshiroGenerator struct {
+	generatorSeed
+	state [4]uint64
+	rest  []byte // bytes of the last generated value not returned by previous read
+	value [8]byte
+}
+
+func (gen *xoshiroGenerator) Seed(key []byte) error {
+	err := gen.set(key)
+	if err != nil {
+		return err
+	}
+	state := gen.expand()
+	for i := range gen.state {
+		gen.state[i] = binary.LittleEndian.Uint64(state[i*8:])
+	}
+	gen.rest = nil
+	return nil
+}
+
+func (gen *xoshiroGenerator) next() uint64 {
+	s := &gen.state
+	result := bits.RotateLeft64(s[1]*5, 7) * 9
+	t := s[1] << 17
+	s[2] ^= s[0]
+	s[3] ^= s[1]
+	s[1] ^= s[2]
+	s[0] ^= s[3]
+	s[2] ^= t
+	s[3] = bits.RotateLeft64(s[3], 45)
+	return result
+}
+
+func (gen *xoshiroGenerator) Read(block []byte) (int, error) {
+	n := copy(block, gen.rest)
+	gen.rest = gen.rest[n:]
+	for ; n+8 <= len(block); n += 8 {
+		binary.LittleEndian.PutUint64(block[n:], gen.next())
+	}
+	if n < len(block) {
+		binary.LittleEndian.PutUint64(gen.value[:], gen.next())
+		copied := copy(block[n:], gen.value[:])
+		gen.rest = gen.value[copied:]
+	}
+	return len(block), nil
+}
+
+func (gen *xoshiroGenerator) Close() error {
+	return nil
+}
+
+func (gen *xoshiroGenerator) Clone() (DataGenerator, error) {
+	return CreateXoshiroDataGenerator(gen.cloneSeed())
+}
+
+// FileGenerator returns generator with seed depending on the file name only
+func (gen *xoshiroGenerator) FileGenerator(name string) (DataGenerator, error) {
+	return CreateXoshiroDataGenerator(gen.fileSeed(name))
+}
+
+// CreateXoshiroDataGenerator creates xoshiro256** generator. It is the fastest one but the data
+// is not cryptographically secure
+func CreateXoshiroDataGenerator(seed []byte) (DataGenerator, error) {
+	gen := &xoshiroGenerator{}
+	err := gen.Seed(seed)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to set seed")
+	}
+	return gen, nil
+}
+
+/* ChaCha8 data generator implementation */
+
+type chacha8Generator struct {
+	generatorSeed
+	chacha *rand.ChaCha8
+}
+
+func (gen *chacha8Generator) Seed(key []byte) error {
+	err := gen.set(key)
+	if err != nil {
+		return err
+	}
+	gen.chacha = rand.NewChaCha8(gen.expand())
+	return nil
+}
+
+func (gen *chacha8Generator) Read(block []byte) (int, error) {
+	return gen.chacha.Read(block)
+}
+
+func (gen *chacha8Generator) Close() error {
+	return nil
+}
+
+func (gen *chacha8Generator) Clone() (DataGenerator, error) {
+	return CreateChaCha8DataGenerator(gen.cloneSeed())
+}
+
+// FileGenerator returns generator with seed depending on the file name only
+func (gen *chacha8Generator) FileGenerator(name string) (DataGenerator, error) {
+	return CreateChaCha8DataGenerator(gen.fileSeed(name))
+}
+
+// CreateChaCha8DataGenerator creates generator of ChaCha8 stream of standard library
+func CreateChaCha8DataGenerator(seed []byte) (DataGenerator, error) {
+	gen := &chacha8Generator{}
+	err := gen.Seed(seed)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to set seed")
+	}
+	return gen, nil
+}
+
+/* AES-CTR data generator implementation */
+
+type aesCTRGenerator struct {
+	generatorSeed
+	stream cipher.Stream
+}
+
+func (gen *aesCTRGenerator) Seed(key []byte) error {
+	err := gen.set(key)
+	if err != nil {
+		return err
+	}
+	block, err := aes.NewCipher(gen.seed)
+	if err != nil {
+		return errors.Wrap(err, "Failed to create AES cipher")
+	}
+	iv := gen.expand()
+	gen.stream = cipher.NewCTR(block, iv[:aes.BlockSize])
+	return nil
+}
+
+// Read returns key stream. It is encryption of zeros which uses AES-NI if CPU supports it
+func (gen *aesCTRGenerator) Read(block []byte) (int, error) {
+	clear(block)
+	gen.stream.XORKeyStream(block, block)
+	return len(block), nil
+}
+
+func (gen *aesCTRGenerator) Close() error {
+	return nil
+}
+
+func (gen *aesCTRGenerator) Clone() (DataGenerator, error) {
+	return CreateAESCTRDataGenerator(gen.cloneSeed())
+}
+
+// FileGenerator returns generator with seed depending on the file name only
+func (gen *aesCTRGenerator) FileGenerator(name string) (DataGenerator, error) {
+	return CreateAESCTRDataGenerator(gen.fileSeed(name))
+}
+
+// CreateAESCTRDataGenerator creates generator of AES-128 key stream in counter mode
+func CreateAESCTRDataGenerator(seed []byte) (DataGenerator, error) {
+	gen := &aesCTRGenerator{}
+	err := gen.Seed(seed)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to set seed")
+	}
+	return gen, nil
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Named pipes creation for Unix-like systems
+*/
+
+package fglib
+
+import (
+	"syscall"
+)
+
+func makeFifo(path string) error {
+	return syscall.Mkfifo(path, 0644)
+}
@@ -0,0 +1,15 @@
+//go:build windows
+// +build windows
+
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Named pipes are not supported on Windows
+*/
+
+package fglib
+
+func makeFifo(path string) error {
+	return ErrNotSupported
+}
@@ -0,0 +1,117 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Filter of files by path, size, modification time and depth
+*/
+
+package fglib
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// FileFilter selects files. Fields with zero values are not checked
+type FileFilter struct {
+	Include       []string       // glob patterns. File matches if any pattern matches
+	Exclude       []string       // glob patterns. File is skipped if any pattern matches
+	IncludeRegexp *regexp.Regexp // matched with relative path with slashes
+	ExcludeRegexp *regexp.Regexp
+	Extensions    []string // extensions with dot. Case is ignored
+
+	MinSize int64
+	MaxSize int64
+	MinAge  time.Duration // minimal time since modification
+	MaxAge  time.Duration
+	// Now is time to compute age from. It is set once, so all walks of files match the same files.
+	// Current time of each check is used if it is zero
+	Now time.Time
+	// Depth of file is count of items in relative path. Files in root have depth 1
+	MinDepth int
+	MaxDepth int
+}
+
+// CheckGlobs returns error if any of glob patterns is invalid
+func (f *FileFilter) CheckGlobs() error {
+	for _, pattern := range append(append([]string{}, f.Include...), f.Exclude...) {
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return fmt.Errorf("Invalid glob pattern '%s'", pattern)
+		}
+	}
+	return nil
+}
+
+// matchGlob checks if pattern matches relative path, name of file or any parent directory.
+// For example 'dir_3*' matches 'dir_3/file_1', '*.txt' matches 'dir_0/file_0.txt'
+func matchGlob(pattern string, name string) bool {
+	if matched, _ := path.Match(pattern, path.Base(name)); matched {
+		return true
+	}
+	for dir := name; dir != "." && dir != "/" && dir != ""; dir = path.Dir(dir) {
+		if matched, _ := path.Match(pattern, dir); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAnyGlob(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match checks file with relative path with slashes
+func (f *FileFilter) Match(name string, info os.FileInfo) bool {
+	if len(f.Include) > 0 && matchAnyGlob(f.Include, name) == false {
+		return false
+	}
+	if matchAnyGlob(f.Exclude, name) {
+		return false
+	}
+	if f.IncludeRegexp != nil && f.IncludeRegexp.MatchString(name) == false {
+		return false
+	}
+	if f.ExcludeRegexp != nil && f.ExcludeRegexp.MatchString(name) {
+		return false
+	}
+
+	if len(f.Extensions) > 0 {
+		ext := path.Ext(name)
+		found := false
+		for _, filterExt := range f.Extensions {
+			if strings.EqualFold(ext, filterExt) {
+				found = true
+			}
+		}
+		if found == false {
+			return false
+		}
+	}
+
+	if info.Size() < f.MinSize || (f.MaxSize > 0 && info.Size() > f.MaxSize) {
+		return false
+	}
+	now := f.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	age := now.Sub(info.ModTime())
+	if age < f.MinAge || (f.MaxAge > 0 && age > f.MaxAge) {
+		return false
+	}
+	depth := strings.Count(name, "/") + 1
+	if depth < f.MinDepth || (f.MaxDepth > 0 && depth > f.MaxDepth) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,131 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Tests for filter of files
+*/
+
+package fglib
+
+import (
+	"os"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// testFileInfo describes file with size and modification time
+type testFileInfo struct {
+	os.FileInfo
+	size    int64
+	modTime time.Time
+}
+
+func (i testFileInfo) Size() int64 {
+	return i.size
+}
+
+func (i testFileInfo) ModTime() time.Time {
+	return i.modTime
+}
+
+func TestFileFilterMatch(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		filter  FileFilter
+		name    string
+		size    int64
+		age     time.Duration
+		matched bool
+	}{
+		{FileFilter{}, "dir_0/file_0", 0, 0, true},
+		{FileFilter{Include: []string{"*.txt"}}, "dir_0/file_0.txt", 0, 0, true},
+		{FileFilter{Include: []string{"*.txt"}}, "dir_0/file_0.bin", 0, 0, false},
+		{FileFilter{Include: []string{"dir_3*"}}, "dir_3/sub/file_1", 0, 0, true},
+		{FileFilter{Include: []string{"dir_3/sub"}}, "dir_3/sub/file_1", 0, 0, true},
+		{FileFilter{Include: []string{"*.bin", "dir_1"}}, "dir_1/file_1.txt", 0, 0, true},
+		{FileFilter{Exclude: []string{"dir_1"}}, "dir_1/file_1.txt", 0, 0, false},
+		{FileFilter{Include: []string{"*.txt"}, Exclude: []string{"file_1*"}}, "dir_0/file_1.txt", 0, 0, false},
+		{FileFilter{IncludeRegexp: regexp.MustCompile(`^dir_[0-2]/`)}, "dir_2/file_1", 0, 0, true},
+		{FileFilter{IncludeRegexp: regexp.MustCompile(`^dir_[0-2]/`)}, "dir_3/file_1", 0, 0, false},
+		{FileFilter{ExcludeRegexp: regexp.MustCompile(`_1$`)}, "dir_3/file_1", 0, 0, false},
+		{FileFilter{Extensions: []string{".jpg", ".txt"}}, "dir_0/file.TXT", 0, 0, true},
+		{FileFilter{Extensions: []string{".jpg"}}, "dir_0/file.jpeg", 0, 0, false},
+		{FileFilter{MinSize: 100}, "file", 100, 0, true},
+		{FileFilter{MinSize: 100}, "file", 99, 0, false},
+		{FileFilter{MaxSize: 100}, "file", 101, 0, false},
+		{FileFilter{MinAge: time.Hour}, "file", 0, 2 * time.Hour, true},
+		{FileFilter{MinAge: time.Hour}, "file", 0, time.Minute, false},
+		{FileFilter{MaxAge: time.Hour}, "file", 0, 2 * time.Hour, false},
+		{FileFilter{MaxAge: time.Hour, Now: now.Add(-90 * time.Minute)}, "file", 0, 2 * time.Hour, true},
+		{FileFilter{MinDepth: 2}, "file", 0, 0, false},
+		{FileFilter{MinDepth: 2, MaxDepth: 3}, "a/b/file", 0, 0, true},
+		{FileFilter{MaxDepth: 2}, "a/b/file", 0, 0, false},
+	}
+	for _, test := range tests {
+		info := testFileInfo{size: test.size, modTime: now.Add(-test.age)}
+		if test.filter.Match(test.name, info) != test.matched {
+			t.Errorf("Filter %+v returned %v for '%s' of %d bytes and age %s", test.filter, !test.matched,
+				test.name, test.size, test.age)
+		}
+	}
+}
+
+// TestFileFilterNow checks that age is computed from the same time for all checks
+func TestFileFilterNow(t *testing.T) {
+	o := &CmdOptions{}
+	err := o.processFilter("", "", "", "", "", "", "", "", "500ms", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := testFileInfo{modTime: time.Now().Add(-400 * time.Millisecond)}
+	if o.Change.Filter.Match("file", info) == false {
+		t.Fatalf("File modified 400ms ago does not match max age 500ms")
+	}
+	time.Sleep(200 * time.Millisecond)
+	if o.Change.Filter.Match("file", info) == false {
+		t.Errorf("Filter matches different files in different checks")
+	}
+}
+
+func TestProcessFilter(t *testing.T) {
+	tests := []struct {
+		include string
+		regexp  string
+		exts    string
+		minSize string
+		maxAge  string
+		filter  FileFilter
+		valid   bool
+	}{
+		{"dir_3*,*.txt", "", "jpg,.txt", "4K", "1h", FileFilter{Include: []string{"dir_3*", "*.txt"},
+			Extensions: []string{".jpg", ".txt"}, MinSize: 4096, MaxAge: time.Hour}, true},
+		{"[", "", "", "", "", FileFilter{}, false},
+		{"", "(", "", "", "", FileFilter{}, false},
+		{"", "", "", "4Q", "", FileFilter{}, false},
+		{"", "", "", "", "-1h", FileFilter{}, false},
+		{"", "", "", "", "day", FileFilter{}, false},
+	}
+	for _, test := range tests {
+		o := &CmdOptions{}
+		err := o.processFilter(test.include, "", test.regexp, "", test.exts, test.minSize, "", "", test.maxAge,
+			0, 0)
+		if (err == nil) != test.valid {
+			t.Errorf("Filter of '%s', '%s', '%s', '%s', '%s' is processed with error: %v", test.include,
+				test.regexp, test.exts, test.minSize, test.maxAge, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		filter := o.Change.Filter
+		if filter.Now.IsZero() {
+			t.Errorf("Time to compute age from is not set")
+		}
+		filter.Now = time.Time{}
+		if reflect.DeepEqual(filter, test.filter) == false {
+			t.Errorf("Filter is processed as %+v instead of %+v", filter, test.filter)
+		}
+	}
+}
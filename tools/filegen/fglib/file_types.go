@@ -0,0 +1,163 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Files types with headers of real formats
+*/
+
+package fglib
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/* Headers of known files types */
+
+func getPNGHeader() []byte {
+	header := []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}
+	ihdr := []byte{'I', 'H', 'D', 'R',
+		0, 0, 4, 0, // width 1024
+		0, 0, 3, 0, // height 768
+		8, 2, 0, 0, 0} // 8-bit RGB without interlace
+	length := make([]byte, 4)
+	binary.BigEndian.PutUint32(length, uint32(len(ihdr)-4))
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE(ihdr))
+	header = append(header, length...)
+	header = append(header, ihdr...)
+	return append(header, crc...)
+}
+
+func getZIPHeader() []byte {
+	name := "data.bin"
+	header := []byte{'P', 'K', 3, 4,
+		20, 0, // version needed to extract
+		0, 0, // flags
+		0, 0, // stored without compression
+		0, 0, 0x21, 0, // 1980-01-01 00:00
+		0, 0, 0, 0, // crc-32
+		0, 0, 0, 0, // compressed size
+		0, 0, 0, 0, // uncompressed size
+		byte(len(name)), 0, // name length
+		0, 0} // extra field length
+	return append(header, name...)
+}
+
+var fileHeaders = map[string][]byte{
+	".jpg":  {0xff, 0xd8, 0xff, 0xe0, 0, 0x10, 'J', 'F', 'I', 'F', 0, 1, 1, 0, 0, 1, 0, 1, 0, 0},
+	".jpeg": {0xff, 0xd8, 0xff, 0xe0, 0, 0x10, 'J', 'F', 'I', 'F', 0, 1, 1, 0, 0, 1, 0, 1, 0, 0},
+	".png":  getPNGHeader(),
+	".gif":  []byte("GIF89a"),
+	".pdf":  []byte("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n"),
+	".zip":  getZIPHeader(),
+	".gz":   {0x1f, 0x8b, 8, 0, 0, 0, 0, 0, 0, 3},
+	".mp3":  {'I', 'D', '3', 3, 0, 0, 0, 0, 0, 0},
+}
+
+// GetFileHeader returns header of file format for extension or nil if format is unknown
+func GetFileHeader(ext string) []byte {
+	return fileHeaders[strings.ToLower(ext)]
+}
+
+/* Mix of files types */
+
+type FileTypesMix struct {
+	exts  []string
+	edges []float64 // cumulative weights in [0;1]
+}
+
+// ParseFileTypesMix parses mix in format: '.ext:weight{%},*'. Weights are normalized
+func ParseFileTypesMix(data string) (*FileTypesMix, error) {
+	mix := &FileTypesMix{}
+	var weights []float64
+	total := float64(0)
+	for _, item := range strings.Split(data, ",") {
+		parts := strings.Split(item, ":")
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid file type format '%s'. Must be '.ext:weight'", item)
+		}
+
+		ext := parts[0]
+		if strings.HasPrefix(ext, ".") == false {
+			ext = "." + ext
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSuffix(parts[1], "%"), 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to parse weight of file type '%s'", item)
+		}
+		if weight < 0 || math.IsInf(weight, 0) || math.IsNaN(weight) {
+			return nil, fmt.Errorf("Invalid weight of file type '%s'. Must be positive", item)
+		}
+		mix.exts = append(mix.exts, ext)
+		weights = append(weights, weight)
+		total += weight
+	}
+
+	if total == 0 {
+		return nil, fmt.Errorf("Sum of files types weights must be positive")
+	}
+	sum := float64(0)
+	for _, weight := range weights {
+		sum += weight
+		mix.edges = append(mix.edges, sum/total)
+	}
+	return mix, nil
+}
+
+// GetExt returns extension for point in [0;1)
+func (m *FileTypesMix) GetExt(point float64) string {
+	for i, edge := range m.edges {
+		if point < edge {
+			return m.exts[i]
+		}
+	}
+	return m.exts[len(m.exts)-1]
+}
+
+// GetMaxExtLength returns length in bytes of the longest extension
+func (m *FileTypesMix) GetMaxExtLength() int {
+	length := 0
+	for _, ext := range m.exts {
+		if len(ext) > length {
+			length = len(ext)
+		}
+	}
+	return length
+}
+
+/* Name generator adding extensions from mix */
+
+type fileTypesNameGenerator struct {
+	names  NameGenerator
+	mix    *FileTypesMix
+	offset float64
+}
+
+func (ng *fileTypesNameGenerator) GetName(index uint) (string, error) {
+	name, err := ng.names.GetName(index)
+	if err != nil {
+		return "", err
+	}
+
+	/* golden ratio sequence keeps proportions of types close to weights for any files count */
+	_, point := math.Modf(ng.offset + float64(index)*(math.Sqrt(5)-1)/2)
+	return name + ng.mix.GetExt(point), nil
+}
+
+// CreateFileTypesNameGenerator creates generator adding extensions to names with proportions
+// set by mix. Order of extensions depends on seed.
+func CreateFileTypesNameGenerator(names NameGenerator, mix *FileTypesMix, seed []byte) NameGenerator {
+	random := getIndexRandom(seed, 0, 8)
+	return &fileTypesNameGenerator{
+		names:  names,
+		mix:    mix,
+		offset: float64(binary.LittleEndian.Uint64(random)) / float64(^uint64(0)),
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Tests for files types mix and headers
+*/
+
+package fglib
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseFileTypesMix(t *testing.T) {
+	tests := []struct {
+		data  string
+		exts  []string
+		edges []float64
+		valid bool
+	}{
+		{".jpg:20%,.txt:50%,.bin:30%", []string{".jpg", ".txt", ".bin"}, []float64{0.2, 0.7, 1}, true},
+		{"jpg:1,txt:3", []string{".jpg", ".txt"}, []float64{0.25, 1}, true},
+		{".pdf:0,.gz:2", []string{".pdf", ".gz"}, []float64{0, 1}, true},
+		{".jpg", nil, nil, false},
+		{":10", nil, nil, false},
+		{".jpg:x", nil, nil, false},
+		{".jpg:-1,.txt:2", nil, nil, false},
+		{".jpg:0,.txt:0", nil, nil, false},
+		{".jpg:Inf", nil, nil, false},
+	}
+	for _, test := range tests {
+		mix, err := ParseFileTypesMix(test.data)
+		if test.valid == false {
+			if err == nil {
+				t.Errorf("Invalid mix '%s' is parsed", test.data)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Failed to parse mix '%s': %v", test.data, err)
+			continue
+		}
+		if reflect.DeepEqual(mix.exts, test.exts) == false {
+			t.Errorf("Mix '%s' has extensions %v instead of %v", test.data, mix.exts, test.exts)
+		}
+		for i, edge := range test.edges {
+			if math.Abs(mix.edges[i]-edge) > 1e-9 {
+				t.Errorf("Mix '%s' has edges %v instead of %v", test.data, mix.edges, test.edges)
+				break
+			}
+		}
+	}
+}
+
+// TestFileTypesProportions checks that count of each type is close to its weight for any files count
+func TestFileTypesProportions(t *testing.T) {
+	mix, err := ParseFileTypesMix(".jpg:20%,.txt:50%,.bin:30%,.pdf:0%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	weights := map[string]float64{".jpg": 0.2, ".txt": 0.5, ".bin": 0.3, ".pdf": 0}
+	for _, count := range []uint{10, 100, 1000} {
+		names := CreateFileTypesNameGenerator(CreatePrefixNameGenerator("file_"), mix, SeedFromUint64(uint64(count)))
+		counts := make(map[string]int)
+		for i := uint(0); i < count; i++ {
+			name, err := names.GetName(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			counts[filepath.Ext(name)]++
+		}
+		for ext, weight := range weights {
+			if math.Abs(float64(counts[ext])-weight*float64(count)) > 2 {
+				t.Errorf("%d of %d files have type '%s' with weight %.2f", counts[ext], count, ext, weight)
+			}
+		}
+	}
+}
+
+func TestFileHeaders(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		ext    string
+		size   uint64
+		prefix []byte
+	}{
+		{".jpg", 1000, []byte{0xff, 0xd8, 0xff}},
+		{".JPEG", 1000, []byte{0xff, 0xd8, 0xff}},
+		{".png", 1000, []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")},
+		{".gif", 1000, []byte("GIF89a")},
+		{".pdf", 1000, []byte("%PDF-1.4")},
+		{".zip", 1000, []byte("PK\x03\x04")},
+		{".gz", 1000, []byte{0x1f, 0x8b, 8}},
+		{".mp3", 1000, []byte("ID3")},
+		{".gif", 3, []byte("GIF")}, // header is truncated to file size
+		{".txt", 1000, nil},
+	}
+	for _, test := range tests {
+		header := GetFileHeader(test.ext)
+		if (header == nil) != (test.prefix == nil) {
+			t.Errorf("Header of '%s' is %v", test.ext, header)
+			continue
+		}
+		path := filepath.Join(dir, "file"+test.ext)
+		err := writeFile(context.Background(), path, test.size, header, CreateNullDataGenerator())
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uint64(len(data)) != test.size || bytes.HasPrefix(data, test.prefix) == false {
+			t.Errorf("File of type '%s' has %d bytes with header %q", test.ext, len(data), data[:len(test.prefix)])
+		}
+	}
+}
@@ -9,16 +9,51 @@ package fglib
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
 )
 
-func writeFile(path string, size uint64, gen DataGenerator) error {
+func isStopped(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// stopContext returns context which is done if parent context is done or stop channel is closed
+func stopContext(parent context.Context, stop chan bool) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// getCanceledError replaces ErrCanceled with error of parent context if it is done
+func getCanceledError(parent context.Context, err error) error {
+	if errors.Cause(err) == ErrCanceled && parent.Err() != nil {
+		return errors.Wrap(parent.Err(), "Processing is stopped")
+	}
+	return err
+}
+
+// writeFile writes header and data from generator after it. Header is truncated to file size.
+// It returns ErrCanceled if context is done before file is written
+func writeFile(ctx context.Context, path string, size uint64, header []byte, gen DataGenerator) error {
 	rawFile, err := os.Create(path)
 	if err != nil {
 		return errors.Wrapf(err, "Failed for create '%s'", path)
@@ -28,67 +63,262 @@ func writeFile(path string, size uint64, gen DataGenerator) error {
 	file := bufio.NewWriter(rawFile)
 	defer file.Flush()
 
+	err = copyData(ctx, file, size, header, gen)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to write to '%s'", path)
+	}
+	return nil
+}
+
+// copyData writes size bytes of header and data from generator after it to writer
+func copyData(ctx context.Context, file io.Writer, size uint64, header []byte, gen DataGenerator) error {
+	if uint64(len(header)) > size {
+		header = header[:size]
+	}
+	_, err := file.Write(header)
+	if err != nil {
+		return errors.Wrap(err, "Failed to write header")
+	}
+	size -= uint64(len(header))
+
 	var bufferSize uint64 = 64 * 1024
 	buffer := make([]byte, bufferSize)
 
 	for size > 0 {
+		if isStopped(ctx) {
+			return ErrCanceled
+		}
 		if size < bufferSize {
 			buffer = buffer[:size]
 		}
 		_, err = gen.Read(buffer)
 		if err != nil {
-			return errors.Wrapf(err, "Failed to write to '%s'", path)
+			return errors.Wrap(err, "Failed to generate data")
+		}
+		_, err = file.Write(buffer)
+		if err != nil {
+			return errors.Wrap(err, "Failed to write data")
 		}
-		file.Write(buffer)
 		size -= uint64(len(buffer))
 	}
 	return nil
 }
 
-type NameGenerator interface {
-	GetName(index uint) (string, error)
-}
-
-type prefixNameGenerator struct {
-	prefix string
-}
-
-func (ng *prefixNameGenerator) GetName(index uint) (string, error) {
-	return fmt.Sprintf("%s%d", ng.prefix, index), nil
-}
-
-func CreatePrefixNameGenerator(prefix string) NameGenerator {
-	return &prefixNameGenerator{prefix: prefix}
-}
-
 type FilesGenerator interface {
 	io.Closer
 	Generate() error
+	// GenerateContext stops generation when context is done and returns error of context
+	GenerateContext(ctx context.Context) error
+	Stop() // stops generation. Generate returns ErrCanceled
 }
 
 type linearFilesGenerator struct {
+	options
 	gen  DataGenerator
 	path string
 
-	dirsCount uint
-	dirNames  NameGenerator
-
+	dirsCount  uint
 	filesCount uint
-	fileNames  NameGenerator
 	fileSize   uint64
+
+	stop     chan bool
+	stopOnce sync.Once
 }
 
 func (g *linearFilesGenerator) Close() error {
 	return g.gen.Close()
 }
 
+func (g *linearFilesGenerator) Stop() {
+	g.stopOnce.Do(func() {
+		close(g.stop)
+	})
+}
+
+// getFileGenerator returns generator of data for file with name. Returned generator is
+// closed with close function
+func (g *linearFilesGenerator) getFileGenerator(name string) (gen DataGenerator, close func(), err error) {
+	fileGen, ok := g.gen.(FileDataGenerator)
+	if ok == false {
+		return g.gen, func() {}, nil
+	}
+
+	gen, err = fileGen.FileGenerator(name)
+	if err == ErrNotSupported {
+		return g.gen, func() {}, nil
+	}
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to create file data generator")
+	}
+	return gen, func() { gen.Close() }, nil
+}
+
+func (g *linearFilesGenerator) writeFile(ctx context.Context, filePath string, name string) error {
+	gen, close, err := g.getFileGenerator(name)
+	if err != nil {
+		return err
+	}
+	defer close()
+	return writeFile(ctx, filePath, g.fileSize, GetFileHeader(filepath.Ext(name)), gen)
+}
+
+// getPath returns path to file with index in generation order
+func (g *linearFilesGenerator) getPath(index uint) (string, error) {
+	dirName, err := g.dirNames.GetName(index / g.filesCount)
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to generate directory name")
+	}
+	fileName, err := g.fileNames.GetName(index % g.filesCount)
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to generate file name")
+	}
+	return filepath.Join(g.path, dirName, fileName), nil
+}
+
+// getSymlinkTarget returns target of symlink with index. Absolute target is got with abs function
+func (g *linearFilesGenerator) getSymlinkTarget(index uint, filePath string,
+	abs func(string) (string, error)) (string, error) {
+	var target string
+	switch g.special.GetSymlinkKind(index) {
+	case SymlinkRelative, SymlinkAbsolute:
+		targetIndex, ok := g.special.GetTarget(index)
+		if ok == false {
+			target = "missing" // there is no file to link to
+			break
+		}
+		targetPath, err := g.getPath(targetIndex)
+		if err != nil {
+			return "", err
+		}
+		if g.special.GetSymlinkKind(index) == SymlinkAbsolute {
+			target, err = abs(targetPath)
+		} else {
+			target, err = filepath.Rel(filepath.Dir(filePath), targetPath)
+		}
+		if err != nil {
+			return "", errors.Wrapf(err, "Failed to get symlink target for '%s'", targetPath)
+		}
+	case SymlinkDangling:
+		target = fmt.Sprintf("missing_%d", index)
+	case SymlinkLoop:
+		target = ".."
+	}
+	return target, nil
+}
+
+func (g *linearFilesGenerator) createSymlink(index uint, filePath string) error {
+	target, err := g.getSymlinkTarget(index, filePath, filepath.Abs)
+	if err != nil {
+		return err
+	}
+	return os.Symlink(target, filePath)
+}
+
+// createEntry creates regular file or special one depending on its index
+func (g *linearFilesGenerator) createEntry(ctx context.Context, index uint, filePath string, name string) error {
+	entryType := EntryRegular
+	if g.special != nil {
+		entryType = g.special.GetEntryType(index)
+	}
+	/* entry could be created before resuming. It is removed because its mode could deny writing
+	   and it could be hard link to other file */
+	err := os.Remove(filePath)
+	if err != nil && os.IsNotExist(err) == false {
+		return errors.Wrapf(err, "Failed to remove '%s'", filePath)
+	}
+
+	switch entryType {
+	case EntrySymlink:
+		return g.createSymlink(index, filePath)
+	case EntryHardlink:
+		targetIndex, ok := g.special.GetTarget(index)
+		if ok == false {
+			err = g.writeFile(ctx, filePath, name)
+			break
+		}
+		targetPath, err := g.getPath(targetIndex)
+		if err != nil {
+			return err
+		}
+		return os.Link(targetPath, filePath)
+	case EntryFifo:
+		return makeFifo(filePath)
+	case EntryEmpty:
+		err = writeFile(ctx, filePath, 0, nil, g.gen)
+	default:
+		err = g.writeFile(ctx, filePath, name)
+	}
+
+	/* ACL is set after mode because chmod changes it */
+	if err == nil && g.attrs != nil {
+		err = g.attrs.ApplyXattrs(filePath, name)
+	}
+	if err == nil && g.perms != nil {
+		err = g.perms.ApplyFile(filePath, name)
+	}
+	if err == nil && g.attrs != nil {
+		err = g.attrs.ApplyACL(filePath, name)
+	}
+	return err
+}
+
+// setDirsPermissions sets permissions and ACL to all directories. It is called after all files
+// are created because directory mode could deny creation of files and hard links to them
+func (g *linearFilesGenerator) setDirsPermissions() error {
+	if g.perms == nil && g.attrs == nil {
+		return nil
+	}
+	for i := uint(0); i < g.dirsCount; i++ {
+		dirName, err := g.dirNames.GetName(i)
+		if err != nil {
+			return errors.Wrap(err, "Failed to generate directory name")
+		}
+		folderPath := filepath.Join(g.path, dirName)
+		if g.perms != nil {
+			err = g.perms.ApplyDir(folderPath, filepath.ToSlash(dirName))
+			if err != nil {
+				return errors.Wrap(err, "Failed to set directory permissions")
+			}
+		}
+		if g.attrs != nil {
+			err = g.attrs.ApplyACL(folderPath, filepath.ToSlash(dirName))
+			if err != nil {
+				return errors.Wrap(err, "Failed to set directory ACL")
+			}
+		}
+	}
+	return nil
+}
+
+func (g *linearFilesGenerator) saveCheckpoint(dir, file uint) error {
+	if g.checkpoint == nil {
+		return nil
+	}
+	g.checkpoint.Dir, g.checkpoint.File = dir, file
+	return g.checkpoint.Save()
+}
+
 func (g *linearFilesGenerator) Generate() error {
+	return g.GenerateContext(context.Background())
+}
+
+func (g *linearFilesGenerator) GenerateContext(parent context.Context) error {
+	ctx, cancel := stopContext(parent, g.stop)
+	defer cancel()
+
 	completeSignal := make(chan bool)
 	errorChannel := make(chan error)
-	filesGenerated := 0
+	filesGenerated := uint64(0)
+
+	startDir, startFile := uint(0), uint(0)
+	if g.checkpoint != nil {
+		startDir, startFile = g.checkpoint.Dir, g.checkpoint.File
+		filesGenerated = uint64(startDir*g.filesCount + startFile)
+	}
 
 	go func() {
-		for i := uint(0); i < g.dirsCount; i++ {
+		lastSaved := time.Now()
+		for i := startDir; i < g.dirsCount; i++ {
 			dirName, err := g.dirNames.GetName(i)
 			if err != nil {
 				errorChannel <- errors.Wrap(err, "Failed to generate directory name")
@@ -96,46 +326,108 @@ func (g *linearFilesGenerator) Generate() error {
 			}
 			folderPath := filepath.Join(g.path, dirName)
 			os.MkdirAll(folderPath, os.ModeDir|0755)
+			if g.attrs != nil && isStopped(ctx) == false {
+				err = g.attrs.ApplyXattrs(folderPath, filepath.ToSlash(dirName))
+				if err != nil {
+					errorChannel <- errors.Wrap(err, "Failed to set directory attributes")
+					return
+				}
+			}
 			for j := uint(0); j < g.filesCount; j++ {
+				if i == startDir && j < startFile {
+					continue // file is completed before resuming
+				}
+				if isStopped(ctx) {
+					g.saveCheckpoint(i, j)
+					errorChannel <- ErrCanceled
+					return
+				}
+				if time.Since(lastSaved) >= time.Second {
+					err = g.saveCheckpoint(i, j)
+					if err != nil {
+						errorChannel <- errors.Wrap(err, "Failed to save checkpoint")
+						return
+					}
+					lastSaved = time.Now()
+				}
+
 				fileName, err := g.fileNames.GetName(j)
 				if err != nil {
 					errorChannel <- errors.Wrap(err, "Failed to generate file name")
+					return
 				}
 				filePath := filepath.Join(folderPath, fileName)
-				err = writeFile(filePath, g.fileSize, g.gen)
+				err = g.createEntry(ctx, i*g.filesCount+j, filePath, filepath.ToSlash(filepath.Join(dirName, fileName)))
+				if errors.Cause(err) == ErrCanceled {
+					os.Remove(filePath) // in-flight file is not completed
+				}
 				if err != nil {
+					g.saveCheckpoint(i, j)
 					errorChannel <- errors.Wrapf(err, "Failed to generate file '%s'", filePath)
+					return
 				}
-				filesGenerated++
+				atomic.AddUint64(&filesGenerated, 1)
+			}
+		}
+		err := g.setDirsPermissions()
+		if err != nil {
+			errorChannel <- err
+			return
+		}
+		if g.checkpoint != nil {
+			err := g.checkpoint.Remove()
+			if err != nil {
+				errorChannel <- err
+				return
 			}
 		}
 		completeSignal <- true
 	}()
 
-	timeout := time.Tick(time.Second)
-	filesTotal := Options.Generate.Folders * Options.Generate.Files
+	timeout := time.NewTicker(g.progressInterval)
+	defer timeout.Stop()
+	filesTotal := uint64(g.dirsCount * g.filesCount)
+	report := func(completed bool) {
+		g.reportProgress(Progress{
+			Processed: atomic.LoadUint64(&filesGenerated),
+			Total:     filesTotal,
+			Completed: completed,
+		})
+	}
 	for {
 		select {
-		case <-timeout:
-			fmt.Printf("\rGenerated: (%d/%d)        ", filesGenerated, filesTotal)
+		case <-timeout.C:
+			report(false)
 		case <-completeSignal:
-			fmt.Printf("\rGenerated: (%d/%d)        \n", filesGenerated, filesTotal)
+			report(true)
 			return nil
 		case err := <-errorChannel:
-			return errors.Wrapf(err, "Failed to generate files")
+			report(true)
+			return errors.Wrapf(getCanceledError(parent, err), "Failed to generate files")
 		}
 	}
 }
 
-func CreateLinearFileGenerator(gen DataGenerator, path string, dirsCount uint, dirNames NameGenerator,
-	filesCount uint, fileNames NameGenerator, fileSize uint64) FilesGenerator {
+// CreateLinearFileGenerator creates generator of dirsCount directories with filesCount files
+// of fileSize in each one. Behaviour is changed with options
+func CreateLinearFileGenerator(gen DataGenerator, path string, dirsCount uint, filesCount uint,
+	fileSize uint64, opts ...Option) (FilesGenerator, error) {
+	return createLinearFileGenerator(componentGenerator, gen, path, dirsCount, filesCount, fileSize, opts)
+}
+
+func createLinearFileGenerator(component int, gen DataGenerator, path string, dirsCount uint,
+	filesCount uint, fileSize uint64, opts []Option) (*linearFilesGenerator, error) {
+	options, err := getOptions(component, opts)
+	if err != nil {
+		return nil, err
+	}
 	return &linearFilesGenerator{
+		options:    options,
 		gen:        gen,
 		path:       path,
 		dirsCount:  dirsCount,
-		dirNames:   dirNames,
 		filesCount: filesCount,
-		fileNames:  fileNames,
 		fileSize:   fileSize,
-	}
+		stop:       make(chan bool),
+	}, nil
 }
@@ -8,12 +8,15 @@ Brief:     Files changer for random generator
 package fglib
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
@@ -25,14 +28,16 @@ type FileSelector interface {
 	IsFileIsSelected() (bool, error)
 }
 
-type randomFileSelector struct { // implements SequenceChecker
-	gen      DataGenerator
-	weight   float64
-	hitScore float64
-	length   uint64
-	count    uint64
-	index    uint64
-	hits     uint64
+// randomFileSelector selects exactly count of total files. Each subset of count files has the
+// same probability. Files are selected with selection sampling: file is selected with probability
+// of count of not selected yet files to count of not checked yet files
+type randomFileSelector struct {
+	gen    DataGenerator // used if seed is not set
+	seed   []byte
+	length uint64
+	count  uint64
+	index  uint64
+	hits   uint64
 }
 
 func (r *randomFileSelector) IsFileIsSelected() (bool, error) {
@@ -40,36 +45,34 @@ func (r *randomFileSelector) IsFileIsSelected() (bool, error) {
 		return false, nil
 	}
 
-	if (r.count - r.hits) == (r.length - r.index) {
-		r.hitScore = 0
-		r.index++
-		r.hits++
-		return true, nil
-	}
-
 	v, err := r.getFloat64()
 	if err != nil {
 		return false, errors.Wrap(err, "Failed to get random float")
 	}
 
 	r.index++
-	if v > r.hitScore {
+	if v*float64(r.length-r.index+1) < float64(r.count-r.hits) {
 		r.hits++
-		r.hitScore *= r.weight
 		return true, nil
 	}
 	return false, nil
 }
 
+// getFloat64 returns random value in [0;1)
 func (r *randomFileSelector) getFloat64() (float64, error) {
 	var v uint64
-	err := binary.Read(r.gen, binary.LittleEndian, &v)
-	if err != nil {
-		return float64(0), errors.Wrap(err, "Failed toread float value from data generator")
+	if r.seed != nil {
+		v = binary.LittleEndian.Uint64(getIndexRandom(r.seed, uint(r.index), 8))
+	} else {
+		err := binary.Read(r.gen, binary.LittleEndian, &v)
+		if err != nil {
+			return float64(0), errors.Wrap(err, "Failed toread float value from data generator")
+		}
 	}
-	return (float64(v) / float64(^uint64(0))), nil
+	return float64(v>>11) / float64(uint64(1)<<53), nil
 }
 
+// CreateRundomFileSelector creates selector of count files from total files with random data of generator
 func CreateRundomFileSelector(gen DataGenerator, count uint64, total uint64) (FileSelector, error) {
 	if total == 0 {
 		return nil, fmt.Errorf("total argument cannot be 0")
@@ -80,14 +83,23 @@ func CreateRundomFileSelector(gen DataGenerator, count uint64, total uint64) (Fi
 	}
 
 	return &randomFileSelector{
-		gen:      gen,
-		count:    count,
-		length:   total,
-		weight:   float64(total-1) / float64(total),
-		hitScore: float64(total-uint64((float64(count)/1.4))) / float64(total),
+		gen:    gen,
+		count:  count,
+		length: total,
 	}, nil
 }
 
+// CreateRandomFileSelector creates selector of count files from total files. The same files are
+// selected for the same seed
+func CreateRandomFileSelector(seed []byte, count uint64, total uint64) (FileSelector, error) {
+	selector, err := CreateRundomFileSelector(nil, count, total)
+	if err != nil {
+		return nil, err
+	}
+	selector.(*randomFileSelector).seed = seed
+	return selector, nil
+}
+
 /* Simple checker that returns always true */
 
 type selectAllFiles struct {
@@ -106,9 +118,14 @@ func CreateAllFilesSelector() FileSelector {
 type FilesModifier interface {
 	io.Closer
 	Modify() error
+	// ModifyContext stops modification when context is done and returns error of context. File in
+	// progress is left changed partially
+	ModifyContext(ctx context.Context) error
+	Stop() // stops modification after file in progress is changed. Modify returns ErrCanceled
 }
 
 type modifyFilesWithIntervals struct {
+	options
 	gen         DataGenerator
 	path        string
 	changeRatio float64
@@ -116,12 +133,21 @@ type modifyFilesWithIntervals struct {
 	interval Interval
 	once     bool // use once if true otherwise until file ending
 	reverse  bool // use interval from the end of file if true
+
+	stop     chan bool
+	stopOnce sync.Once
 }
 
 func (m *modifyFilesWithIntervals) Close() error {
 	return m.gen.Close()
 }
 
+func (m *modifyFilesWithIntervals) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+}
+
 func min(a int64, b int64) int64 {
 	if a <= b {
 		return a
@@ -129,84 +155,153 @@ func min(a int64, b int64) int64 {
 	return b
 }
 
-func (m *modifyFilesWithIntervals) changeFile(path string, info os.FileInfo) error {
-	i := GetObsoleteInterval(m.interval, info.Size())
-	if i.Modify.Value == 0 {
-		return nil
+// contextReader stops reading when context is done. Count of read bytes is added to read if it is set
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+	read   *uint64
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if isStopped(r.ctx) {
+		return 0, ErrCanceled
+	}
+	n, err := r.reader.Read(p)
+	if r.read != nil {
+		atomic.AddUint64(r.read, uint64(n))
 	}
+	return n, err
+}
 
-	file, err := os.OpenFile(path, os.O_RDWR, 0755)
+// isDevice returns true for block devices
+func isDevice(info os.FileInfo) bool {
+	return info.Mode()&os.ModeDevice != 0 && info.Mode()&os.ModeCharDevice == 0
+}
+
+// getTargetSize returns size of block device or regular file
+func getTargetSize(path string, info os.FileInfo) (int64, error) {
+	if isDevice(info) == false {
+		return info.Size(), nil
+	}
+	file, err := os.Open(path)
 	if err != nil {
-		return errors.Wrapf(err, "Failed to open file '%s'", path)
+		return 0, errors.Wrapf(err, "Failed to open device '%s'", path)
 	}
 	defer file.Close()
+	size, err := getDeviceSize(file)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Failed to get size of device '%s'", path)
+	}
+	return size, nil
+}
 
-	size := info.Size()
-	writeSize := int64(0)
-	offset, newOffset := int64(0), int64(0)
-	for offset < size {
-		if i.NotModify.Value > 0 {
-			if (size - newOffset) < i.NotModify.Value {
-				break
-			}
-			newOffset += i.NotModify.Value
+// syncFile flushes written data to storage
+func syncFile(path string) error {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to open '%s'", path)
+	}
+	defer file.Close()
+	err = file.Sync()
+	if err != nil {
+		return errors.Wrapf(err, "Failed to sync '%s'", path)
+	}
+	return nil
+}
+
+// changeFile changes file or block device of size. Count of written bytes is added to written if it is set
+func (m *modifyFilesWithIntervals) changeFile(ctx context.Context, path string, size int64, written *uint64) error {
+	/* file is opened with the first range to change, so files without ranges are not opened */
+	var file *os.File
+	defer func() {
+		if file != nil {
+			file.Close()
 		}
+	}()
 
-		if m.reverse == true || newOffset != offset {
-			if m.reverse == true {
-				if (size - newOffset) < i.Modify.Value {
-					_, err := file.Seek(0, io.SeekStart)
-					if err != nil {
-						return errors.Wrap(err, "Failed to seek")
-					}
-				} else {
-					_, err := file.Seek(size-newOffset-i.Modify.Value, io.SeekStart)
-					if err != nil {
-						return errors.Wrap(err, "Failed to seek")
-					}
-				}
-				writeSize = min(size-newOffset, i.Modify.Value)
-			} else {
-				_, err := file.Seek(newOffset, io.SeekStart)
-				if err != nil {
-					return errors.Wrap(err, "Failed to seek")
-				}
-				writeSize = min(i.Modify.Value, size-newOffset)
+	return WalkIntervalRanges(m.interval, size, m.once, m.reverse, func(r ByteRange) error {
+		if file == nil {
+			var err error
+			file, err = os.OpenFile(path, os.O_RDWR, 0755)
+			if err != nil {
+				return errors.Wrapf(err, "Failed to open file '%s'", path)
 			}
-			offset = newOffset
-		} else {
-			writeSize = min(i.Modify.Value, size-newOffset)
 		}
 
-		writen, err := io.CopyN(file, m.gen, writeSize)
-		newOffset += writen
-		offset = newOffset
+		_, err := file.Seek(r.Offset, io.SeekStart)
 		if err != nil {
-			return errors.Wrap(err, "Failed to copy data from data generator")
+			return errors.Wrap(err, "Failed to seek")
 		}
-
-		if m.once == true {
-			break
+		var genOffset uint64
+		if m.journal != nil {
+			genOffset = atomic.LoadUint64(&m.gen.(*countingGenerator).read)
 		}
 
-		if i.NotModifyUntil.Value > 0 {
-			if (size - newOffset) < i.NotModifyUntil.Value {
-				break
+		n, err := io.CopyN(file, &contextReader{ctx: ctx, reader: m.gen, read: written}, r.Length)
+		if m.journal != nil && n > 0 {
+			journalErr := m.addJournalEntry(path, r.Offset, n, genOffset)
+			if journalErr != nil {
+				return journalErr
 			}
-			newOffset += i.NotModifyUntil.Value
 		}
+		if err != nil {
+			return errors.Wrap(err, "Failed to copy data from data generator")
+		}
+		return nil
+	})
+}
+
+// addJournalEntry adds range written to file from offset of generator stream. Path of block device
+// or image is stored as '.'
+func (m *modifyFilesWithIntervals) addJournalEntry(path string, offset, length int64, genOffset uint64) error {
+	name, err := filepath.Rel(m.path, path)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to get relative path for '%s'", path)
 	}
-	return nil
+	return m.journal.Add(JournalEntry{
+		Path:            filepath.ToSlash(name),
+		Offset:          offset,
+		Length:          length,
+		GeneratorOffset: genOffset,
+	})
+}
+
+func (m *modifyFilesWithIntervals) applyAttributes(path string) error {
+	name, err := filepath.Rel(m.path, path)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to get relative path for '%s'", path)
+	}
+	return m.attrs.Apply(path, filepath.ToSlash(name))
+}
+
+// isChangeable checks if file could be selected to change. Directories, links and special files
+// are not changed
+func (m *modifyFilesWithIntervals) isChangeable(path string, info os.FileInfo) (bool, error) {
+	if info.Mode().IsRegular() == false {
+		return false, nil
+	}
+	if m.filter == nil {
+		return true, nil
+	}
+	name, err := filepath.Rel(m.path, path)
+	if err != nil {
+		return false, errors.Wrapf(err, "Failed to get relative path for '%s'", path)
+	}
+	return m.filter.Match(filepath.ToSlash(name), info), nil
 }
 
-func (m *modifyFilesWithIntervals) getFilesCount() (filesCount int64, err error) {
+func (m *modifyFilesWithIntervals) getFilesCount(ctx context.Context) (filesCount int64, err error) {
 	filesCount = 0
 	err = filepath.Walk(m.path, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		if info.IsDir() {
-			return nil
+		if isStopped(ctx) {
+			return ErrCanceled
+		}
+		changeable, err := m.isChangeable(path, info)
+		if err != nil || changeable == false {
+			return err
 		}
 		filesCount++
 		return nil
@@ -214,34 +309,108 @@ func (m *modifyFilesWithIntervals) getFilesCount() (filesCount int64, err error)
 	return
 }
 
+// isSelective returns true if not all changeable files are selected. Selector of files requires
+// count of changeable files in this case
+func (m *modifyFilesWithIntervals) isSelective() bool {
+	return m.changeRatio < 1 || m.selectCount > 0
+}
+
+// createFileSelector creates selector of files from total changeable files with skew. It returns
+// count of files to select
+func (m *modifyFilesWithIntervals) createFileSelector(total uint64, skew *Skew) (FileSelector, uint64, error) {
+	count := uint64(math.Round(m.changeRatio * float64(total)))
+	if m.selectCount > 0 {
+		count = m.selectCount
+	}
+	if count > total {
+		count = total // all files are selected
+	}
+
+	var selector FileSelector
+	var err error
+	if total == 0 {
+		selector = CreateAllFilesSelector()
+	} else if skew != nil && skew.Type != SkewUniform {
+		selector, err = CreateSkewedFileSelector(m.gen, m.selectSeed, skew, m.hotSeed, count, total)
+	} else if m.selectSeed != nil {
+		selector, err = CreateRandomFileSelector(m.selectSeed, count, total)
+	} else {
+		selector, err = CreateRundomFileSelector(m.gen, count, total)
+	}
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "Failed to create rundom file selector")
+	}
+	return selector, count, nil
+}
+
 func (m *modifyFilesWithIntervals) Modify() error {
-	completeSignal := make(chan bool)
-	errorChannel := make(chan error)
-	filesProcessed := 0
-	failed := false
+	return m.ModifyContext(context.Background())
+}
+
+// modifyTarget changes block device or regular file used as device image. Progress is
+// reported in written bytes
+func (m *modifyFilesWithIntervals) modifyTarget(ctx context.Context, info os.FileInfo) error {
+	size, err := getTargetSize(m.path, info)
+	if err != nil {
+		return err
+	}
+
+	written := uint64(0)
+	return m.runWithProgress(func() error {
+		err := m.changeFile(ctx, m.path, size, &written)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to change '%s'", m.path)
+		}
+		if isDevice(info) {
+			return syncFile(m.path)
+		}
+		return nil
+	}, func() Progress {
+		return Progress{Processed: atomic.LoadUint64(&written)}
+	})
+}
+
+func (m *modifyFilesWithIntervals) ModifyContext(parent context.Context) error {
+	ctx, cancel := stopContext(parent, m.stop)
+	defer cancel()
+
+	info, err := os.Stat(m.path)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to get info of '%s'", m.path)
+	}
+	if info.IsDir() == false {
+		return getCanceledError(parent, m.modifyTarget(ctx, info))
+	}
+
+	/* channels are buffered, so goroutines are not blocked when the first error is returned */
+	completeSignal := make(chan bool, 1)
+	errorChannel := make(chan error, 2)
+	filesProcessed := uint64(0)
+	failed := int32(0)
 	var totalFiles int64
 	var fileSelector FileSelector
-	var wg sync.WaitGroup
 
-	if m.changeRatio < 1 {
+	if m.isSelective() {
 		var err error
-		totalFiles, err = m.getFilesCount()
+		totalFiles, err = m.getFilesCount(ctx)
 		if err != nil {
-			return errors.Wrap(err, "Failed to get files count")
+			return errors.Wrap(getCanceledError(parent, err), "Failed to get files count")
 		}
-		fileSelector, err = CreateRundomFileSelector(m.gen, uint64(m.changeRatio*float64(totalFiles)), uint64(totalFiles))
+		var count uint64
+		fileSelector, count, err = m.createFileSelector(uint64(totalFiles), m.skew)
 		if err != nil {
-			return errors.Wrap(err, "Failed to create rundom file selector")
+			return err
 		}
+		totalFiles = int64(count) // progress is reported for selected files
 	} else {
 		go func() {
-			var err error
-			totalFiles, err = m.getFilesCount()
-			if err != nil {
+			count, err := m.getFilesCount(ctx)
+			if err != nil && err != ErrCanceled {
 				errorChannel <- errors.Wrap(err, "Failed to get files count")
+				return
 			}
+			atomic.StoreInt64(&totalFiles, count)
 		}()
-		wg.Add(1)
 		fileSelector = CreateAllFilesSelector()
 	}
 
@@ -250,23 +419,31 @@ func (m *modifyFilesWithIntervals) Modify() error {
 			if err != nil {
 				return err
 			}
-			if failed {
+			if atomic.LoadInt32(&failed) != 0 {
 				return fmt.Errorf("Canceled")
 			}
-			if info.IsDir() {
-				return nil
+			if isStopped(ctx) {
+				return ErrCanceled
+			}
+			changeable, err := m.isChangeable(path, info)
+			if err != nil || changeable == false {
+				return err
 			}
 			r, err := fileSelector.IsFileIsSelected()
 			if err != nil {
 				return errors.Wrap(err, "Failed to check if file is selected to change")
 			}
 			if r == true {
-				err = m.changeFile(path, info)
-				filesProcessed++
+				/* file is changed completely on stop, done parent context aborts changing of file */
+				err = m.changeFile(parent, path, info.Size(), nil)
+				if err == nil && m.attrs != nil {
+					err = m.applyAttributes(path)
+				}
+				atomic.AddUint64(&filesProcessed, 1)
 			}
 			return err
 		})
-		if failed {
+		if atomic.LoadInt32(&failed) != 0 {
 			return
 		}
 
@@ -276,41 +453,52 @@ func (m *modifyFilesWithIntervals) Modify() error {
 		}
 		completeSignal <- true
 	}()
-	wg.Add(1)
 
-	report := func() {
-		if totalFiles == 0 {
-			fmt.Printf("\rProcessed: %d        ", filesProcessed)
-		} else {
-			fmt.Printf("\rProcessed: (%d/%d)        ", filesProcessed, totalFiles)
-		}
+	report := func(completed bool) {
+		m.reportProgress(Progress{
+			Processed: atomic.LoadUint64(&filesProcessed),
+			Total:     uint64(atomic.LoadInt64(&totalFiles)),
+			Completed: completed,
+		})
 	}
 
-	timeout := time.Tick(time.Second)
+	timeout := time.NewTicker(m.progressInterval)
+	defer timeout.Stop()
 	for {
 		select {
-		case <-timeout:
-			report()
+		case <-timeout.C:
+			report(false)
 		case <-completeSignal:
-			report()
-			fmt.Println("")
+			report(true)
 			return nil
 		case err := <-errorChannel:
-			failed = true
-			wg.Wait()
-			return err
+			atomic.StoreInt32(&failed, 1)
+			report(true)
+			return getCanceledError(parent, err)
 		}
 	}
 }
 
+// CreateFilesModifierWithInterval creates modifier of changeRatio of files with interval. Files are
+// selected uniformly. Behaviour is changed with options
 func CreateFilesModifierWithInterval(gen DataGenerator, path string, changeRatio float64,
-	interval Interval, once, reverse bool) FilesModifier {
-	return &modifyFilesWithIntervals{
+	interval Interval, once, reverse bool, opts ...Option) (FilesModifier, error) {
+	options, err := getOptions(componentModifier, opts)
+	if err != nil {
+		return nil, err
+	}
+	m := &modifyFilesWithIntervals{
+		options:     options,
 		gen:         gen,
 		path:        path,
 		changeRatio: changeRatio,
 		interval:    interval,
 		once:        once,
 		reverse:     reverse,
+		stop:        make(chan bool),
+	}
+	if m.journal != nil {
+		m.gen = &countingGenerator{DataGenerator: gen} // offsets of data are stored to journal
 	}
+	return m, nil
 }
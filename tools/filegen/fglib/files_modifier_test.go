@@ -0,0 +1,447 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Tests for files modifier
+*/
+
+package fglib
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TestModifyHugeFile changes sparse file larger than 2^40 bytes near the end of file
+func TestModifyHugeFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "image")
+	size := int64(1<<41 + 12345)
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = file.Truncate(size)
+	file.Close()
+	if err != nil {
+		t.Skipf("Sparse file of %d bytes is not supported: %v", size, err)
+	}
+
+	tests := []struct {
+		interval string
+		offset   int64
+		length   int64
+	}{
+		{"99.9999999%,1K", 2199023265697, 1024},
+		{"-4K,4K", size - 4096, 4096},
+		{"-0.000001%,100%", size - size/100000000, size / 100000000},
+	}
+	for _, test := range tests {
+		interval, err := ParseInterval(test.interval)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gen, err := CreatePseudoRandomDataGenerator(make([]byte, 16))
+		if err != nil {
+			t.Fatal(err)
+		}
+		modifier, err := CreateFilesModifierWithInterval(gen, path, 1, interval, true, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = modifier.Modify()
+		modifier.Close()
+		if err != nil {
+			t.Fatalf("Failed to modify with interval '%s': %v", test.interval, err)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != size {
+			t.Fatalf("Size of file is changed to %d with interval '%s'", info.Size(), test.interval)
+		}
+		checkModifiedRange(t, path, test.offset, test.length, test.interval)
+	}
+}
+
+// checkModifiedRange checks that range has random data and bytes around the range are zero
+func checkModifiedRange(t *testing.T, path string, offset, length int64, interval string) {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if length > 1<<20 {
+		length = 1 << 20 // data at the beginning of long range is enough
+	}
+	data := make([]byte, length+1)
+	_, err = file.ReadAt(data, offset-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[0] != 0 {
+		t.Errorf("Byte before range at %d is changed with interval '%s'", offset, interval)
+	}
+	if bytes.Count(data[1:], []byte{0}) > len(data)/64 {
+		t.Errorf("Range at %d of %d bytes is not changed with interval '%s'", offset, length, interval)
+	}
+}
+
+// TestJournalMatchesStream checks that data of ranges stored to journal is equal to data of generator
+// stream at offsets of ranges for ranges which are not aligned to blocks of generator
+func TestJournalMatchesStream(t *testing.T) {
+	seed := SeedFromUint64(9)
+	for _, intervalText := range []string{"0,1000", "7,13,29", "10%,33.3%"} {
+		dir := t.TempDir()
+		writeTreeFiles(t, dir, map[string]string{
+			"file_0": string(make([]byte, 4099)),
+			"file_1": string(make([]byte, 65536)),
+			"file_2": string(make([]byte, 100001)),
+		})
+		journalPath := filepath.Join(t.TempDir(), "journal")
+		journal, err := CreateJournal(journalPath, "pseudo", seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		interval, err := ParseInterval(intervalText)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gen, err := CreatePseudoRandomDataGenerator(seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		modifier, err := CreateFilesModifierWithInterval(gen, dir, 1, interval, false, false, WithJournal(journal))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = modifier.Modify()
+		modifier.Close()
+		journal.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		/* stream is read by blocks as stream command does */
+		stream, err := CreatePseudoRandomDataGenerator(seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := make([]byte, 0, 200000)
+		block := make([]byte, 64*1024)
+		for len(data) < cap(data) {
+			stream.Read(block)
+			data = append(data, block...)
+		}
+
+		file, err := os.Open(journalPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries := 0
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var entry JournalEntry
+			err = json.Unmarshal(scanner.Bytes(), &entry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries++
+			written := make([]byte, entry.Length)
+			target, err := os.Open(filepath.Join(dir, entry.Path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = target.ReadAt(written, entry.Offset)
+			target.Close()
+			if err != nil && err != io.EOF {
+				t.Fatal(err)
+			}
+			expected := data[entry.GeneratorOffset : entry.GeneratorOffset+uint64(entry.Length)]
+			if bytes.Equal(written, expected) == false {
+				t.Errorf("%s: range %s %d-%d differs from stream at %d", intervalText, entry.Path, entry.Offset,
+					entry.Offset+entry.Length, entry.GeneratorOffset)
+			}
+		}
+		file.Close()
+		if entries == 0 {
+			t.Errorf("%s: journal is empty", intervalText)
+		}
+	}
+}
+
+// stoppingGenerator calls stop on the first read
+type stoppingGenerator struct {
+	DataGenerator
+	stop func()
+}
+
+func (g *stoppingGenerator) Read(block []byte) (int, error) {
+	g.stop()
+	runtime.Gosched() // stop is delivered to context by goroutine
+	return g.DataGenerator.Read(block)
+}
+
+// TestStopModifyCompletesFile checks that file in progress is changed completely before stopping
+func TestStopModifyCompletesFile(t *testing.T) {
+	dir := t.TempDir()
+	const fileSize = 1 << 20
+	zeros := string(make([]byte, fileSize))
+	writeTreeFiles(t, dir, map[string]string{"a": zeros, "b": zeros, "c": zeros})
+	interval, err := ParseInterval("0,4K")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen, err := CreatePseudoRandomDataGenerator(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopping := &stoppingGenerator{DataGenerator: gen}
+	modifier, err := CreateFilesModifierWithInterval(stopping, dir, 1, interval, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer modifier.Close()
+	stopping.stop = modifier.Stop
+
+	err = modifier.Modify()
+	if errors.Cause(err) != ErrCanceled {
+		t.Fatalf("Modify returned %v instead of %v", err, ErrCanceled)
+	}
+
+	changed := 0
+	for _, name := range []string{"a", "b", "c"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tail := data[fileSize-4096:]
+		if bytes.Equal(data[:4096], make([]byte, 4096)) == false {
+			changed++
+			if bytes.Equal(tail, make([]byte, 4096)) {
+				t.Errorf("File '%s' is changed partially", name)
+			}
+		} else if bytes.Equal(tail, make([]byte, 4096)) == false {
+			t.Errorf("File '%s' is changed after stop", name)
+		}
+	}
+	if changed != 1 {
+		t.Errorf("%d files are changed instead of 1", changed)
+	}
+}
+
+// slowGenerator sleeps before each read
+type slowGenerator struct {
+	DataGenerator
+	delay time.Duration
+}
+
+func (g *slowGenerator) Read(block []byte) (int, error) {
+	time.Sleep(g.delay)
+	return g.DataGenerator.Read(block)
+}
+
+// TestDeadlineModifyStopsFile checks that deadline of context stops changing of file in progress
+func TestDeadlineModifyStopsFile(t *testing.T) {
+	dir := t.TempDir()
+	const fileSize = 64 << 20
+	file, err := os.Create(filepath.Join(dir, "large"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = file.Truncate(fileSize)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	interval, err := ParseInterval("0,64K")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen, err := CreatePseudoRandomDataGenerator(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	modifier, err := CreateFilesModifierWithInterval(&slowGenerator{DataGenerator: gen, delay: time.Millisecond},
+		dir, 1, interval, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer modifier.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = modifier.ModifyContext(ctx)
+	if errors.Cause(err) != context.DeadlineExceeded {
+		t.Fatalf("Modify returned %v instead of %v", err, context.DeadlineExceeded)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "large"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(data[:4096], make([]byte, 4096)) {
+		t.Errorf("File is not changed before deadline")
+	}
+	if bytes.Equal(data[fileSize-4096:], make([]byte, 4096)) == false {
+		t.Errorf("File is changed completely after deadline")
+	}
+}
+
+// getSelection returns indexes of selected files and checks that selector selects nothing after total files
+func getSelection(t *testing.T, selector FileSelector, total uint64) []uint64 {
+	selected := []uint64{}
+	for i := uint64(0); i < total+10; i++ {
+		r, err := selector.IsFileIsSelected()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r && i >= total {
+			t.Fatalf("File %d is selected after %d files", i, total)
+		}
+		if r {
+			selected = append(selected, i)
+		}
+	}
+	return selected
+}
+
+func TestRandomFileSelectorCount(t *testing.T) {
+	tests := []struct {
+		count uint64
+		total uint64
+	}{
+		{0, 10},
+		{1, 1},
+		{5, 10},
+		{10, 10},
+		{37, 1000},
+		{999, 1000},
+	}
+	for _, test := range tests {
+		seeded, err := CreateRandomFileSelector(SeedFromUint64(test.total), test.count, test.total)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gen, err := CreatePseudoRandomDataGenerator(SeedFromUint64(test.count))
+		if err != nil {
+			t.Fatal(err)
+		}
+		random, err := CreateRundomFileSelector(gen, test.count, test.total)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, selector := range []FileSelector{seeded, random} {
+			selected := getSelection(t, selector, test.total)
+			if uint64(len(selected)) != test.count {
+				t.Errorf("%d files are selected from %d instead of %d", len(selected), test.total, test.count)
+			}
+		}
+	}
+}
+
+func TestRandomFileSelectorSeed(t *testing.T) {
+	selections := [3][]uint64{}
+	for i, seed := range []uint64{5, 5, 6} {
+		selector, err := CreateRandomFileSelector(SeedFromUint64(seed), 50, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		selections[i] = getSelection(t, selector, 1000)
+	}
+	if reflect.DeepEqual(selections[0], selections[1]) == false {
+		t.Errorf("Different files are selected for the same seed")
+	}
+	if reflect.DeepEqual(selections[0], selections[2]) {
+		t.Errorf("The same files are selected for different seeds")
+	}
+}
+
+func TestRandomFileSelectorErrors(t *testing.T) {
+	tests := []struct {
+		count uint64
+		total uint64
+	}{
+		{1, 0},
+		{11, 10},
+	}
+	for _, test := range tests {
+		_, err := CreateRandomFileSelector(SeedFromUint64(1), test.count, test.total)
+		if err == nil {
+			t.Errorf("Selector of %d files from %d is created", test.count, test.total)
+		}
+	}
+}
+
+// TestRandomFileSelectorUniformity checks that each file is selected with probability count/total
+func TestRandomFileSelectorUniformity(t *testing.T) {
+	const count, total, runs = 5, 20, 4000
+	hits := make([]int, total)
+	for run := 0; run < runs; run++ {
+		selector, err := CreateRandomFileSelector(SeedFromUint64(uint64(run)), count, total)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, i := range getSelection(t, selector, total) {
+			hits[i]++
+		}
+	}
+	expected := float64(runs * count / total)
+	for i, n := range hits {
+		if math.Abs(float64(n)-expected) > 0.15*expected {
+			t.Errorf("File %d is selected %d times instead of about %.0f", i, n, expected)
+		}
+	}
+}
+
+// TestModifySelectedCountOfAllFiles checks that all files are changed if count is more than files count
+func TestModifySelectedCountOfAllFiles(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{"a", "b", "c"}
+	tree := make(map[string]string)
+	for _, name := range names {
+		tree[name] = string(make([]byte, 4096))
+	}
+	writeTreeFiles(t, dir, tree)
+	interval, err := ParseInterval("0,1K")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen, err := CreatePseudoRandomDataGenerator(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	modifier, err := CreateFilesModifierWithInterval(gen, dir, 1, interval, true, false,
+		WithSelectedCount(10), WithSelectionSeed(SeedFromUint64(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer modifier.Close()
+	err = modifier.Modify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Equal(data[:1024], make([]byte, 1024)) {
+			t.Errorf("File '%s' is not changed", name)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Files reader to measure read throughput and latency
+*/
+
+package fglib
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ReadEnum
+const (
+	ReadSequential = iota
+	ReadRandom
+)
+
+// seekableGenerator can generate data from offset without generating data before it
+type seekableGenerator interface {
+	seek(offset uint64) int // offset is from the beginning of data
+}
+
+// fileVerifier generates expected data of file at any offset. Generator is created once per file
+type fileVerifier struct {
+	header   []byte
+	gen      DataGenerator
+	seekable seekableGenerator
+}
+
+// createFileVerifier creates verifier of file with name and fileSize generated by gen
+func createFileVerifier(gen FileDataGenerator, name string, fileSize int64) (*fileVerifier, error) {
+	header := GetFileHeader(path.Ext(name))
+	if int64(len(header)) > fileSize {
+		header = header[:fileSize]
+	}
+	fileGen, err := gen.FileGenerator(name)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create file data generator")
+	}
+	seekable, ok := fileGen.(seekableGenerator)
+	if ok == false {
+		fileGen.Close()
+		return nil, errors.Wrap(ErrNotSupported, "Generator cannot be used for verification")
+	}
+	return &fileVerifier{
+		header:   header,
+		gen:      fileGen,
+		seekable: seekable,
+	}, nil
+}
+
+// getExpectedData returns size bytes at offset of file
+func (v *fileVerifier) getExpectedData(offset int64, size int) ([]byte, error) {
+	expected := make([]byte, 0, size)
+	if offset < int64(len(v.header)) {
+		end := offset + int64(size)
+		if end > int64(len(v.header)) {
+			end = int64(len(v.header))
+		}
+		expected = append(expected, v.header[offset:end]...)
+	}
+	if len(expected) == size {
+		return expected, nil
+	}
+
+	/* data is generated by blocks, so generation is started from the beginning of block */
+	dataOffset := offset + int64(len(expected)) - int64(len(v.header))
+	drop := v.seekable.seek(uint64(dataOffset))
+	data := make([]byte, drop+size-len(expected))
+	_, err := v.gen.Read(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to generate data")
+	}
+	return append(expected, data[drop:]...), nil
+}
+
+func (v *fileVerifier) Close() error {
+	return v.gen.Close()
+}
+
+type ReadStats struct {
+	Files      uint64
+	Bytes      uint64
+	Duration   time.Duration
+	Latency    LatencyStats // latency of each read operation
+	Mismatches uint64       // count of files with data different from expected
+}
+
+type FilesReader interface {
+	Read() (*ReadStats, error)
+	// ReadContext stops reading when context is done and returns error of context
+	ReadContext(ctx context.Context) (*ReadStats, error)
+	Stop() // stops reading. Read returns ErrCanceled
+}
+
+type readTask struct {
+	path  string
+	names []string // relative paths with slashes. Hard links to the same file have several names
+	size  int64
+}
+
+// inode identifies file with hard links
+type inode struct {
+	dev uint64
+	ino uint64
+}
+
+type filesReader struct {
+	options
+	path        string
+	access      int // ReadEnum
+	blockSize   int
+	concurrency int
+
+	files uint64 // atomic counters
+	bytes uint64
+
+	stop     chan bool
+	stopOnce sync.Once
+}
+
+func (r *filesReader) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+}
+
+// createVerifiers creates verifiers of file for each its name
+func (r *filesReader) createVerifiers(task readTask) ([]*fileVerifier, error) {
+	verifiers := []*fileVerifier{}
+	for _, name := range task.names {
+		verifier, err := createFileVerifier(r.verify, name, task.size)
+		if err != nil {
+			for _, v := range verifiers {
+				v.Close()
+			}
+			return nil, err
+		}
+		verifiers = append(verifiers, verifier)
+	}
+	return verifiers, nil
+}
+
+// getOffsetsRandom returns source of random offsets of file. Offsets depend on seed and path of file
+// only if WithOffsetsSeed is set, so the same blocks are read again
+func (r *filesReader) getOffsetsRandom(task readTask, random *rand.Rand) *rand.Rand {
+	if r.offsetsSeed == nil {
+		return random
+	}
+	seed := getIndexRandom(append(append([]byte{}, r.offsetsSeed...), task.names[0]...), 0, 8)
+	return rand.New(rand.NewSource(int64(binary.LittleEndian.Uint64(seed))))
+}
+
+// readFile reads file by blocks. It returns false if data differs from expected data of all its names
+func (r *filesReader) readFile(ctx context.Context, task readTask, buffer []byte, random *rand.Rand,
+	latency *LatencyStats) (bool, error) {
+	file, err := os.Open(task.path)
+	if err != nil {
+		return false, errors.Wrapf(err, "Failed to open '%s'", task.path)
+	}
+	defer file.Close()
+
+	var verifiers []*fileVerifier
+	if r.verify != nil {
+		verifiers, err = r.createVerifiers(task)
+		if err != nil {
+			return false, err
+		}
+		defer func() {
+			for _, v := range verifiers {
+				v.Close()
+			}
+		}()
+	}
+
+	blockSize := int64(len(buffer))
+	blocks := (task.size + blockSize - 1) / blockSize
+	if r.access == ReadRandom {
+		random = r.getOffsetsRandom(task, random)
+	}
+	matching := verifiers // verifiers of names with data matched so far
+	for i := int64(0); i < blocks; i++ {
+		if isStopped(ctx) {
+			return false, ErrCanceled
+		}
+		offset := i * blockSize
+		if r.access == ReadRandom {
+			offset = random.Int63n(blocks) * blockSize
+		}
+		length := blockSize
+		if task.size-offset < length {
+			length = task.size - offset
+		}
+
+		start := time.Now()
+		n, err := file.ReadAt(buffer[:length], offset)
+		latency.Add(time.Since(start))
+		atomic.AddUint64(&r.bytes, uint64(n))
+		if err != nil && err != io.EOF {
+			return false, errors.Wrapf(err, "Failed to read '%s'", task.path)
+		}
+
+		matched := []*fileVerifier{}
+		for _, verifier := range matching {
+			expected, err := verifier.getExpectedData(offset, int(length))
+			if err != nil {
+				return false, err
+			}
+			if bytes.Equal(buffer[:n], expected) {
+				matched = append(matched, verifier)
+			}
+		}
+		matching = matched
+	}
+	return r.verify == nil || len(matching) > 0, nil
+}
+
+// walk sends tasks to read regular files. If data is verified, file with hard links is read once
+// after walk because any of its names could be the name it is generated with
+func (r *filesReader) walk(ctx context.Context, tasks chan readTask) error {
+	defer close(tasks)
+	send := func(task readTask) error {
+		select {
+		case tasks <- task:
+			return nil
+		case <-ctx.Done():
+			return ErrCanceled
+		}
+	}
+
+	linked := make(map[inode]*readTask)
+	err := filepath.Walk(r.path, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() == false {
+			return nil
+		}
+		name, err := filepath.Rel(r.path, filePath)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to get relative path for '%s'", filePath)
+		}
+		name = filepath.ToSlash(name)
+		if r.verify != nil {
+			if id, links := getInode(info); links > 1 {
+				if task, ok := linked[id]; ok {
+					task.names = append(task.names, name)
+				} else {
+					linked[id] = &readTask{path: filePath, names: []string{name}, size: info.Size()}
+				}
+				return nil
+			}
+		}
+		return send(readTask{path: filePath, names: []string{name}, size: info.Size()})
+	})
+	if err != nil {
+		return err
+	}
+	for _, task := range linked {
+		err = send(*task)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *filesReader) read(ctx context.Context, stats *ReadStats) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var guard sync.Mutex
+	var firstErr error
+	fail := func(err error) {
+		guard.Lock()
+		defer guard.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	tasks := make(chan readTask, r.concurrency)
+	var completed sync.WaitGroup
+	for i := 0; i < r.concurrency; i++ {
+		completed.Add(1)
+		go func(worker int) {
+			defer completed.Done()
+			var latency LatencyStats
+			buffer := make([]byte, r.blockSize)
+			random := rand.New(rand.NewSource(time.Now().UnixNano() + int64(worker)))
+			for task := range tasks {
+				matched, err := r.readFile(ctx, task, buffer, random, &latency)
+				if err != nil {
+					fail(err)
+					break
+				}
+				if matched == false {
+					log.Printf("Data of '%s' differs from expected\n", task.path)
+					atomic.AddUint64(&stats.Mismatches, 1)
+				}
+				atomic.AddUint64(&r.files, 1)
+			}
+			guard.Lock()
+			stats.Latency.Merge(&latency)
+			guard.Unlock()
+		}(i)
+	}
+
+	err := r.walk(ctx, tasks)
+	if err != nil {
+		fail(errors.Wrap(err, "Failed to walk files"))
+	}
+	completed.Wait()
+	return firstErr
+}
+
+func (r *filesReader) Read() (*ReadStats, error) {
+	return r.ReadContext(context.Background())
+}
+
+func (r *filesReader) ReadContext(parent context.Context) (*ReadStats, error) {
+	ctx, cancel := stopContext(parent, r.stop)
+	defer cancel()
+
+	stats := &ReadStats{}
+	start := time.Now()
+	err := r.runWithProgress(func() error {
+		return r.read(ctx, stats)
+	}, func() Progress {
+		return Progress{Processed: atomic.LoadUint64(&r.files)}
+	})
+	stats.Duration = time.Since(start)
+	stats.Files = atomic.LoadUint64(&r.files)
+	stats.Bytes = atomic.LoadUint64(&r.bytes)
+	if err != nil {
+		return stats, errors.Wrap(getCanceledError(parent, err), "Failed to read files")
+	}
+	if stats.Mismatches > 0 {
+		return stats, fmt.Errorf("Data of %d files differs from expected", stats.Mismatches)
+	}
+	return stats, nil
+}
+
+// CreateFilesReader creates reader of all regular files in path with access of ReadEnum type by
+// blocks of blockSize bytes in concurrency goroutines. Data is verified if WithVerification is set
+func CreateFilesReader(path string, access int, blockSize int, concurrency int, opts ...Option) (FilesReader, error) {
+	if blockSize <= 0 {
+		return nil, fmt.Errorf("Block size must be positive")
+	}
+	if concurrency <= 0 {
+		return nil, fmt.Errorf("Concurrency must be positive")
+	}
+	options, err := getOptions(componentReader, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &filesReader{
+		options:     options,
+		path:        path,
+		access:      access,
+		blockSize:   blockSize,
+		concurrency: concurrency,
+		stop:        make(chan bool),
+	}, nil
+}
@@ -0,0 +1,194 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Tests for files reader and verification of data
+*/
+
+package fglib
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// TestFileVerifier checks that expected data at any offset is the same as data of generated file
+func TestFileVerifier(t *testing.T) {
+	gen, err := CreatePseudoRandomDataGenerator(SeedFromUint64(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	const size = 10000
+	for _, name := range []string{"file.bin", "file.png", "file.gif"} {
+		path := filepath.Join(root, name)
+		fileGen, err := gen.(FileDataGenerator).FileGenerator(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = writeFile(context.Background(), path, size, GetFileHeader(filepath.Ext(name)), fileGen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		verifier, err := createFileVerifier(gen.(FileDataGenerator), name, size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		random := rand.New(rand.NewSource(1))
+		for i := 0; i < 100; i++ {
+			offset := random.Int63n(size)
+			length := 1 + random.Intn(int(size-offset))
+			expected, err := verifier.getExpectedData(offset, length)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Equal(expected, data[offset:offset+int64(length)]) == false {
+				t.Fatalf("Expected data of '%s' at %d of %d bytes differs from file", name, offset, length)
+			}
+		}
+		verifier.Close()
+	}
+}
+
+// TestReadVerify checks that changed files are detected for any access and block size
+func TestReadVerify(t *testing.T) {
+	seed := SeedFromUint64(2)
+	special, err := CreateSpecialFiles(SeedFromUint64(3), 0.1, 0.3, 0, 0.1, []int{SymlinkRelative})
+	if err != nil {
+		t.Fatal(err)
+	}
+	/* the last regular file is changed. There are no hard links to it because links are to previous files */
+	changed := uint(29)
+	for special.GetEntryType(changed) != EntryRegular {
+		changed--
+	}
+	tests := []struct {
+		access      int
+		blockSize   int
+		concurrency int
+		change      func(path string) error
+		mismatches  uint64
+	}{
+		{ReadSequential, 4096, 1, nil, 0},
+		{ReadRandom, 7, 3, nil, 0},
+		{ReadSequential, 100000, 2, nil, 0},
+		{ReadRandom, 1000, 1, func(path string) error {
+			file, err := os.OpenFile(path, os.O_WRONLY, 0)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			_, err = file.WriteAt(make([]byte, 5000), 0) // random reads could skip any single changed block
+			return err
+		}, 1},
+		{ReadSequential, 1000, 1, func(path string) error {
+			file, err := os.OpenFile(path, os.O_WRONLY, 0)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			_, err = file.WriteAt([]byte{0}, 2345)
+			return err
+		}, 1},
+		{ReadSequential, 1024, 2, func(path string) error {
+			file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			_, err = file.Write([]byte("tail"))
+			return err
+		}, 1},
+		{ReadSequential, 4096, 1, func(path string) error {
+			return os.Rename(path, path+".moved")
+		}, 1},
+	}
+	for _, test := range tests {
+		root := t.TempDir()
+		gen, err := CreatePseudoRandomDataGenerator(seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		generateTree(t, gen, root, 3, 10, 5000, WithSpecialFiles(special))
+		if test.change != nil {
+			err = test.change(filepath.Join(root, fmt.Sprintf("dir_%d", changed/10),
+				fmt.Sprintf("file_%d", changed%10)))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		gen, err = CreatePseudoRandomDataGenerator(seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader, err := CreateFilesReader(root, test.access, test.blockSize, test.concurrency,
+			WithVerification(gen.(FileDataGenerator)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats, err := reader.Read()
+		if stats == nil || stats.Mismatches != test.mismatches || (err == nil) != (test.mismatches == 0) {
+			t.Errorf("Reading with access %d by %d bytes returned %+v, %v instead of %d mismatches",
+				test.access, test.blockSize, stats, err, test.mismatches)
+		}
+	}
+}
+
+func TestReadVerifyUnsupported(t *testing.T) {
+	root := t.TempDir()
+	generateTree(t, CreateNullDataGenerator(), root, 1, 1, 100)
+	gen, err := CreateXoshiroDataGenerator(SeedFromUint64(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := CreateFilesReader(root, ReadSequential, 4096, 1, WithVerification(gen.(FileDataGenerator)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = reader.Read()
+	if errors.Cause(err) != ErrNotSupported {
+		t.Errorf("Verification with xoshiro generator returned error: %v", err)
+	}
+}
+
+// TestReadOffsetsSeed checks that random offsets depend on seed and path of file only
+func TestReadOffsetsSeed(t *testing.T) {
+	getOffsets := func(seed []byte, name string) []int64 {
+		reader, err := CreateFilesReader("", ReadRandom, 1, 1, WithOffsetsSeed(seed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		random := reader.(*filesReader).getOffsetsRandom(readTask{names: []string{name}},
+			rand.New(rand.NewSource(1)))
+		offsets := make([]int64, 16)
+		for i := range offsets {
+			offsets[i] = random.Int63n(1 << 20)
+		}
+		return offsets
+	}
+
+	expected := getOffsets(SeedFromUint64(1), "dir_0/file_0")
+	if reflect.DeepEqual(getOffsets(SeedFromUint64(1), "dir_0/file_0"), expected) == false {
+		t.Error("Offsets differ for the same seed and path")
+	}
+	if reflect.DeepEqual(getOffsets(SeedFromUint64(2), "dir_0/file_0"), expected) {
+		t.Error("Offsets are the same for other seed")
+	}
+	if reflect.DeepEqual(getOffsets(SeedFromUint64(1), "dir_0/file_1"), expected) {
+		t.Error("Offsets are the same for other path")
+	}
+}
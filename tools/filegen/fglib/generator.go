@@ -8,6 +8,7 @@ Brief:     Common data generator interfaces and tools
 package fglib
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -24,6 +25,12 @@ type DataGenerator interface {
 	Seed(key []byte) error
 }
 
+// FileDataGenerator is implemented by generators which can produce reproducible data
+// for a file independently from data generated for other files
+type FileDataGenerator interface {
+	FileGenerator(name string) (DataGenerator, error)
+}
+
 /* Queues implementations */
 
 type DataQueue interface {
@@ -53,42 +60,34 @@ func CreateUnorderedQueue() DataQueue {
 
 type orderedQueue struct {
 	dest      chan []byte
-	buffer    map[int][]byte
 	guard     sync.Mutex
+	turn      *sync.Cond // signaled when nextIndex is changed
 	nextIndex int
 	maxIndex  int
 }
 
 func (q *orderedQueue) SetDestination(dest chan []byte, density int) {
 	q.dest = dest
-	q.buffer = make(map[int][]byte)
+	q.turn = sync.NewCond(&q.guard)
 	q.nextIndex = 0
 	q.maxIndex = density
 }
 
+// ProcessBlock waits for turn of index, so the next block of the same index is not processed
+// before the previous one is sent
 func (q *orderedQueue) ProcessBlock(index int, data []byte) {
 	q.guard.Lock()
-	defer q.guard.Unlock()
-	if index != q.nextIndex {
-		q.buffer[index] = data
-		return
+	for index != q.nextIndex {
+		q.turn.Wait()
 	}
+	q.guard.Unlock()
 
 	q.dest <- data
-	nextIndex := func() {
-		q.nextIndex = (q.nextIndex + 1) % q.maxIndex
-	}
-	nextIndex()
-	for {
-		var ok bool
-		data, ok = q.buffer[q.nextIndex]
-		if ok == false {
-			return
-		}
-		q.dest <- data
-		delete(q.buffer, q.nextIndex)
-		nextIndex()
-	}
+
+	q.guard.Lock()
+	q.nextIndex = (q.nextIndex + 1) % q.maxIndex
+	q.turn.Broadcast()
+	q.guard.Unlock()
 }
 
 func CreateOrderedQueue() DataQueue {
@@ -102,21 +101,28 @@ type mutiThreadGenerator struct {
 	data      chan []byte
 	queue     DataQueue
 	block     []byte
-	stopping  bool
+	ctx       context.Context
+	cancel    context.CancelFunc
 	completed sync.WaitGroup
 }
 
-func generateRoutine(queue DataQueue, generator io.ReadCloser, index int, stopping *bool, completed *sync.WaitGroup) {
+func generateRoutine(ctx context.Context, queue DataQueue, generator io.ReadCloser, index int,
+	completed *sync.WaitGroup) {
 	defer completed.Done()
 	defer generator.Close()
 	blockSize := 1024 * 1024
-	block := make([]byte, blockSize)
-	processCompleted := make(chan bool)
+	processCompleted := make(chan bool, 1)
 	for {
+		/* block is owned by reader after it is queued, so each block is new */
+		block := make([]byte, blockSize)
 		read, err := generator.Read(block)
 		if err != nil {
 			log.Print(errors.Wrap(err, "Failed to generate data"))
-			time.Sleep(100 * time.Millisecond)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
 			continue
 		}
 
@@ -125,28 +131,17 @@ func generateRoutine(queue DataQueue, generator io.ReadCloser, index int, stoppi
 			processCompleted <- true
 		}()
 
-		processed := false
-		for {
-			if *stopping == true {
-				return
-			}
-
-			if processed == true {
-				break
-			}
-
-			select {
-			case <-processCompleted:
-				processed = true
-				break // Go to generate of new block
-			case <-time.After(time.Second):
-				// Check if complete flag is set
-			}
+		select {
+		case <-ctx.Done():
+			return
+		case <-processCompleted:
+			// Go to generate of new block
 		}
 	}
 }
 
-func (gen *mutiThreadGenerator) init() error {
+func (gen *mutiThreadGenerator) init(ctx context.Context) error {
+	gen.ctx, gen.cancel = context.WithCancel(ctx)
 	if gen.generator == nil {
 		return fmt.Errorf("Generator is not set")
 	}
@@ -155,7 +150,6 @@ func (gen *mutiThreadGenerator) init() error {
 	gen.data = make(chan []byte, cpuCount*2)
 	gen.queue.SetDestination(gen.data, cpuCount)
 	gen.block = nil
-	gen.stopping = false
 
 	/* start generating goroutines */
 	for i := 0; i < cpuCount; i++ {
@@ -164,7 +158,7 @@ func (gen *mutiThreadGenerator) init() error {
 			return errors.Wrap(err, "Failed to clone generator")
 		}
 		gen.completed.Add(1)
-		go generateRoutine(gen.queue, reader, i, &gen.stopping, &gen.completed)
+		go generateRoutine(gen.ctx, gen.queue, reader, i, &gen.completed)
 	}
 	return nil
 }
@@ -174,47 +168,57 @@ func (gen *mutiThreadGenerator) Seed(seed []byte) error {
 }
 
 func (gen *mutiThreadGenerator) Close() error {
-	gen.stopping = true
+	gen.cancel()
 	gen.completed.Wait()
 	return nil
 }
 
+// Read fills p with generated data and returns count of read bytes
 func (gen *mutiThreadGenerator) Read(p []byte) (int, error) {
-	offset := 0
-	size := len(p)
-	for size > 0 {
-		if gen.block == nil {
-			gen.block = <-gen.data
-		}
-
-		blockSize := len(gen.block)
-		if blockSize <= size {
-			copy(p[offset:], gen.block)
-			offset += blockSize
-			size -= blockSize
-			gen.block = nil
-			continue
+	read := 0
+	for read < len(p) {
+		if len(gen.block) == 0 {
+			select {
+			case gen.block = <-gen.data:
+			case <-gen.ctx.Done():
+				return read, ErrCanceled
+			}
 		}
 
-		copy(p[offset:], gen.block[:size])
-		gen.block = gen.block[size:]
-		break
+		n := copy(p[read:], gen.block)
+		gen.block = gen.block[n:]
+		read += n
 	}
-
-	return size, nil
+	return read, nil
 }
 
 func (gen *mutiThreadGenerator) Clone() (DataGenerator, error) {
 	return nil, ErrNotSupported
 }
 
+func (gen *mutiThreadGenerator) FileGenerator(name string) (DataGenerator, error) {
+	fileGen, ok := gen.generator.(FileDataGenerator)
+	if ok == false {
+		return nil, ErrNotSupported
+	}
+	return fileGen.FileGenerator(name)
+}
+
 func CreateMutliThreadGenerator(generator DataGenerator, queue DataQueue) (DataGenerator, error) {
+	return CreateMutliThreadGeneratorContext(context.Background(), generator, queue)
+}
+
+// CreateMutliThreadGeneratorContext creates generator which goroutines are stopped when context
+// is done or generator is closed. Read returns ErrCanceled after it
+func CreateMutliThreadGeneratorContext(ctx context.Context, generator DataGenerator,
+	queue DataQueue) (DataGenerator, error) {
 	gen := &mutiThreadGenerator{
 		generator: generator,
 		queue:     queue,
 	}
-	err := gen.init()
+	err := gen.init(ctx)
 	if err != nil {
+		gen.Close()
 		return nil, errors.Wrap(err, "Failed to create multi-thread data generator")
 	}
 	return gen, nil
@@ -0,0 +1,203 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Tests and benchmarks for data generators and queues
+*/
+
+package fglib
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMultiThreadGeneratorRead(t *testing.T) {
+	gen, err := CreateMutliThreadGenerator(CreateNullDataGenerator(), CreateOrderedQueue())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gen.Close()
+
+	for _, size := range []int{1, 1000, 1024 * 1024, 3*1024*1024 + 5} {
+		n, err := gen.Read(make([]byte, size))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != size {
+			t.Errorf("Read returned %d bytes instead of %d", n, size)
+		}
+	}
+}
+
+// TestOrderedQueue checks that blocks of producers are sent in circle of indexes even if producers
+// process blocks faster than they are sent
+func TestOrderedQueue(t *testing.T) {
+	const producers, blocks = 4, 100
+	dest := make(chan []byte, 2)
+	queue := CreateOrderedQueue()
+	queue.SetDestination(dest, producers)
+	for index := 0; index < producers; index++ {
+		go func(index int) {
+			for i := 0; i < blocks; i++ {
+				queue.ProcessBlock(index, []byte{byte(index), byte(i)})
+			}
+		}(index)
+	}
+	for i := 0; i < producers*blocks; i++ {
+		block := <-dest
+		if int(block[0]) != i%producers || int(block[1]) != i/producers {
+			t.Fatalf("Got block %d of producer %d at position %d", block[1], block[0], i)
+		}
+	}
+}
+
+// TestSeededGenerators checks that data does not depend on sizes of reads, clones get the next seeds
+// and data of files depends on names
+func TestSeededGenerators(t *testing.T) {
+	for name, create := range map[string]func(seed []byte) (DataGenerator, error){
+		"xoshiro": CreateXoshiroDataGenerator,
+		"chacha8": CreateChaCha8DataGenerator,
+		"aes-ctr": CreateAESCTRDataGenerator,
+		"pseudo":  CreatePseudoRandomDataGenerator,
+	} {
+		whole, err := create(SeedFromUint64(7))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := make([]byte, 1000)
+		whole.Read(expected)
+
+		parts, err := create(SeedFromUint64(7))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := make([]byte, 0, len(expected))
+		for _, size := range []int{3, 5, 8, 13, 100, 871} {
+			part := make([]byte, size)
+			if n, err := parts.Read(part); err != nil || n != size {
+				t.Fatalf("%s: read %d bytes of %d: %v", name, n, size, err)
+			}
+			data = append(data, part...)
+		}
+		if bytes.Equal(data, expected) == false {
+			t.Errorf("%s: data depends on sizes of reads", name)
+		}
+
+		/* the first clone gets seed of generator and the next clones get the next seeds */
+		var cloned [2][]byte
+		for i := range cloned {
+			clone, err := parts.Clone()
+			if err != nil {
+				t.Fatal(err)
+			}
+			cloned[i] = make([]byte, len(expected))
+			clone.Read(cloned[i])
+		}
+		if bytes.Equal(cloned[0], expected) == false || bytes.Equal(cloned[1], expected) {
+			t.Errorf("%s: clones do not get the next seeds", name)
+		}
+
+		/* data of file depends on the initial seed and the file name only */
+		var files [3][]byte
+		for i, gen := range []DataGenerator{whole, parts, parts} {
+			fileGen, err := gen.(FileDataGenerator).FileGenerator([]string{"a/b", "a/b", "a/c"}[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[i] = make([]byte, len(expected))
+			fileGen.Read(files[i])
+		}
+		if bytes.Equal(files[0], files[1]) == false || bytes.Equal(files[1], files[2]) {
+			t.Errorf("%s: data of file does not depend on its name only", name)
+		}
+
+		if _, err := create(make([]byte, 8)); err == nil {
+			t.Errorf("%s: seed of 8 bytes is accepted", name)
+		}
+	}
+}
+
+const benchBlockSize = 1024 * 1024
+
+func benchmarkGenerator(b *testing.B, gen DataGenerator) {
+	defer gen.Close()
+	block := make([]byte, benchBlockSize)
+	b.SetBytes(benchBlockSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := gen.Read(block); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCryptoGenerator(b *testing.B) {
+	benchmarkGenerator(b, CreateCryptoDataGenerator())
+}
+
+func BenchmarkPseudoRandomGenerator(b *testing.B) {
+	gen, err := CreatePseudoRandomDataGenerator(SeedFromUint64(1))
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkGenerator(b, gen)
+}
+
+func BenchmarkNullGenerator(b *testing.B) {
+	benchmarkGenerator(b, CreateNullDataGenerator())
+}
+
+// Queues are measured with multi-thread generator of null data, so the queue is the bottleneck
+
+func BenchmarkOrderedQueue(b *testing.B) {
+	gen, err := CreateMutliThreadGenerator(CreateNullDataGenerator(), CreateOrderedQueue())
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkGenerator(b, gen)
+}
+
+func BenchmarkUnorderedQueue(b *testing.B) {
+	gen, err := CreateMutliThreadGenerator(CreateNullDataGenerator(), CreateUnorderedQueue())
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkGenerator(b, gen)
+}
+
+func BenchmarkMultiThreadPseudoRandomGenerator(b *testing.B) {
+	dataGen, err := CreatePseudoRandomDataGenerator(SeedFromUint64(1))
+	if err != nil {
+		b.Fatal(err)
+	}
+	gen, err := CreateMutliThreadGenerator(dataGen, CreateOrderedQueue())
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkGenerator(b, gen)
+}
+
+func BenchmarkXoshiroGenerator(b *testing.B) {
+	gen, err := CreateXoshiroDataGenerator(SeedFromUint64(1))
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkGenerator(b, gen)
+}
+
+func BenchmarkChaCha8Generator(b *testing.B) {
+	gen, err := CreateChaCha8DataGenerator(SeedFromUint64(1))
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkGenerator(b, gen)
+}
+
+func BenchmarkAESCTRGenerator(b *testing.B) {
+	gen, err := CreateAESCTRDataGenerator(SeedFromUint64(1))
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkGenerator(b, gen)
+}
@@ -0,0 +1,131 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Shared helpers of tests to build and read trees of files
+*/
+
+package fglib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// generateTree generates tree of dirs directories with files of fileSize in each one
+func generateTree(t *testing.T, gen DataGenerator, path string, dirs, files uint, fileSize uint64, opts ...Option) {
+	filesGen, err := CreateLinearFileGenerator(gen, path, dirs, files, fileSize, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = filesGen.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeTreeFiles writes files with data by relative paths with slashes
+func writeTreeFiles(t *testing.T, root string, files map[string]string) {
+	for name, data := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(path, []byte(data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readTree returns data of regular files and targets of symlinks by relative paths
+func readTree(t *testing.T, root string) map[string]string {
+	tree := make(map[string]string)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			tree[name] = "-> " + target
+		case info.Mode().IsRegular():
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			tree[name] = string(data)
+		case info.IsDir():
+			tree[name+"/"] = ""
+		default:
+			tree[name] = info.Mode().Type().String()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+// generateAttributes generates tree with attributes and returns entries of its manifest. Test is skipped
+// if file system does not support attributes
+func generateAttributes(t *testing.T, root string, xattrs, aclEntries int, opts ...Option) []ManifestEntry {
+	manifestPath := filepath.Join(t.TempDir(), "manifest")
+	manifest, err := CreateManifest(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attrs, err := CreateAttributes(SeedFromUint64(1), xattrs, 32, aclEntries, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts = append(opts, WithAttributes(attrs))
+	filesGen, err := CreateLinearFileGenerator(CreateNullDataGenerator(), root, 2, 5, 100, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = filesGen.Generate()
+	if errors.Cause(err) == syscall.ENOTSUP {
+		t.Skip("Extended attributes or ACL are not supported")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = manifest.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []ManifestEntry{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var entry ManifestEntry
+		err = json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Identification of hard links for Unix-like systems
+*/
+
+package fglib
+
+import (
+	"os"
+	"syscall"
+)
+
+// getInode returns identifier of file and count of its hard links
+func getInode(info os.FileInfo) (inode, uint64) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if ok == false {
+		return inode{}, 1
+	}
+	return inode{dev: uint64(stat.Dev), ino: uint64(stat.Ino)}, uint64(stat.Nlink)
+}
@@ -0,0 +1,21 @@
+//go:build windows
+// +build windows
+
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Hard links are not identified on Windows
+*/
+
+package fglib
+
+import (
+	"os"
+)
+
+// getInode returns identifier of file and count of its hard links. Each file is treated as
+// file without hard links
+func getInode(info os.FileInfo) (inode, uint64) {
+	return inode{}, 1
+}
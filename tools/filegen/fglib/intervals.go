@@ -9,8 +9,9 @@ package fglib
 
 import (
 	"fmt"
+	"math/big"
+	"math/bits"
 	"regexp"
-	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -21,6 +22,14 @@ import (
 type IntervalValue struct {
 	Value    int64 // value
 	Obsolete bool  // if true, value stored in bytes otherwise in percents
+	Decimals int   // count of decimals of percents. For example, Value 125 with 1 decimal is 12.5%
+	FromEnd  bool  // if true, value is counted from the end of file
+}
+
+// ByteRange is range of file data in bytes
+type ByteRange struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
 }
 
 // Intervals are present with 3 values [size not to modify; size to modify; size not to modify
@@ -46,12 +55,38 @@ func GetEmptyInterval() Interval {
 	}
 }
 
+// getPercentsOfSize returns percents of size rounded down. Product is computed in 128 bits, so it
+// does not overflow for any size of file
+func getPercentsOfSize(size int64, percents int64, decimals int) int64 {
+	if size <= 0 || percents <= 0 {
+		return 0
+	}
+	divisor := uint64(100)
+	for i := 0; i < decimals; i++ {
+		divisor *= 10
+	}
+	if uint64(percents) >= divisor {
+		return size
+	}
+	hi, lo := bits.Mul64(uint64(size), uint64(percents))
+	quo, _ := bits.Div64(hi, lo, divisor) // hi < divisor because percents < divisor
+	return int64(quo)
+}
+
 func GetObsoleteInterval(interval Interval, size int64) Interval {
 	result := interval
 	makeObsolete := func(v *IntervalValue) {
 		if v.Obsolete == false {
-			v.Value = int64(float64(v.Value*size) / float64(100))
+			v.Value = getPercentsOfSize(size, v.Value, v.Decimals)
 			v.Obsolete = true
+			v.Decimals = 0
+		}
+		if v.FromEnd {
+			v.Value = size - v.Value
+			if v.Value < 0 {
+				v.Value = 0
+			}
+			v.FromEnd = false
 		}
 	}
 	makeObsolete(&result.NotModify)
@@ -60,9 +95,142 @@ func GetObsoleteInterval(interval Interval, size int64) Interval {
 	return result
 }
 
-// Interval format [digit{,kK,mM,gG,%},*3]. First value to seek without modification.
-// The next one is to modify file. The third is for seeking.
+// WalkIntervalRanges calls walk for each range of file of size to modify with interval. Interval is
+// applied from the beginning of file or from the end of file if reverse is true, once or until the
+// end of file. Ranges are in order of modification, they are within file and do not overlap. Ranges
+// are not stored, so memory does not depend on count of ranges. Error of walk stops walking
+func WalkIntervalRanges(interval Interval, size int64, once, reverse bool, walk func(r ByteRange) error) error {
+	i := GetObsoleteInterval(interval, size)
+	if i.Modify.Value <= 0 || size <= 0 {
+		return nil
+	}
+	notModify, notModifyUntil := i.NotModify.Value, i.NotModifyUntil.Value
+	if notModify < 0 {
+		notModify = 0
+	}
+	if notModifyUntil < 0 {
+		notModifyUntil = 0
+	}
+
+	/* offsets are counted from the end of file for reverse interval */
+	offset := int64(0)
+	for {
+		if notModify >= size-offset {
+			break
+		}
+		offset += notModify
+
+		length := min(i.Modify.Value, size-offset)
+		r := ByteRange{Offset: offset, Length: length}
+		if reverse {
+			r.Offset = size - offset - length
+		}
+		err := walk(r)
+		if err != nil {
+			return err
+		}
+		offset += length
+
+		if once || notModifyUntil >= size-offset {
+			break
+		}
+		offset += notModifyUntil
+	}
+	return nil
+}
+
+/* Parsing of sizes and intervals */
+
+var sizeRegexp = regexp.MustCompile(`^(-?)(\d+)(?:\.(\d+))?([a-zA-Z%]*)$`)
+
+// maxPercentsDecimals limits precision of percents, so 100% fits to int64 with any precision
+const maxPercentsDecimals = 9
+
+// getSizeMultiplier returns count of bytes for suffix of size. Lower case one letter suffixes are
+// decimal (SI) and upper case ones are binary as before. Suffixes 'KB', 'MB', ... are decimal and
+// 'KiB', 'MiB', ... are binary in any case
+func getSizeMultiplier(suffix string) (int64, bool) {
+	const (
+		kilo = 1000
+		kibi = 1024
+	)
+	powers := map[byte]uint{'K': 1, 'M': 2, 'G': 3, 'T': 4, 'P': 5}
+	pow := func(base int64, n uint) int64 {
+		result := int64(1)
+		for i := uint(0); i < n; i++ {
+			result *= base
+		}
+		return result
+	}
 
+	switch {
+	case suffix == "" || suffix == "B" || suffix == "b":
+		return 1, true
+	case len(suffix) == 1:
+		if n, ok := powers[suffix[0]]; ok {
+			return pow(kibi, n), true
+		}
+		if n, ok := powers[strings.ToUpper(suffix)[0]]; ok {
+			return pow(kilo, n), true
+		}
+	case len(suffix) == 2 && strings.EqualFold(suffix[1:], "B"):
+		if n, ok := powers[strings.ToUpper(suffix)[0]]; ok {
+			return pow(kilo, n), true
+		}
+	case len(suffix) == 3 && strings.EqualFold(suffix[1:], "iB"):
+		if n, ok := powers[strings.ToUpper(suffix)[0]]; ok {
+			return pow(kibi, n), true
+		}
+	}
+	return 0, false
+}
+
+// parseIntervalValue parses value in bytes or in percents if allowed. Value in bytes could have
+// decimal fraction, it is rounded down to whole bytes. Negative value is returned with FromEnd
+func parseIntervalValue(data string, allowPercents bool) (IntervalValue, error) {
+	r := sizeRegexp.FindStringSubmatch(data)
+	if r == nil {
+		return IntervalValue{}, fmt.Errorf("Invalid size '%s'. Must be number with optional fraction "+
+			"and suffix. For example: 512, 4K, 1.5G, 10MiB", data)
+	}
+	negative, integer, fraction, suffix := r[1] == "-", r[2], r[3], r[4]
+	mantissa, ok := new(big.Int).SetString(integer+fraction, 10)
+	if ok == false {
+		return IntervalValue{}, fmt.Errorf("Failed to parse number '%s'", data)
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(len(fraction))), nil)
+
+	if suffix == "%" {
+		if allowPercents == false {
+			return IntervalValue{}, fmt.Errorf("Invalid size '%s'. Percents are not allowed", data)
+		}
+		if len(fraction) > maxPercentsDecimals {
+			return IntervalValue{}, fmt.Errorf("Invalid percents '%s'. Must have not more than %d decimals",
+				data, maxPercentsDecimals)
+		}
+		if mantissa.Cmp(new(big.Int).Mul(scale, big.NewInt(100))) > 0 {
+			return IntervalValue{}, fmt.Errorf("Invalid percents '%s'. Must be in [0;100]", data)
+		}
+		return IntervalValue{Value: mantissa.Int64(), Decimals: len(fraction), FromEnd: negative}, nil
+	}
+
+	multiplier, ok := getSizeMultiplier(suffix)
+	if ok == false {
+		return IntervalValue{}, fmt.Errorf("Invalid suffix '%s' of size '%s'. Must be one of: k, K, m, M, "+
+			"g, G, t, T, p, P, B, kB, MB, GB, TB, PB, KiB, MiB, GiB, TiB, PiB", suffix, data)
+	}
+	value := mantissa.Mul(mantissa, big.NewInt(multiplier))
+	value.Quo(value, scale)
+	if value.IsInt64() == false {
+		return IntervalValue{}, fmt.Errorf("Size '%s' is too large", data)
+	}
+	return IntervalValue{Value: value.Int64(), Obsolete: true, FromEnd: negative}, nil
+}
+
+// ParseInterval parses interval in format 'value,value{,value}'. First value is to seek without
+// modification. The next one is to modify file. The third one is to seek after modification.
+// Values are in format of ParseSize or in percents of file size with '%' suffix, for example '12.5%'.
+// Only the first value could be negative to count it from the end of file
 func ParseInterval(data string) (result Interval, err error) {
 	intervals := strings.Split(data, ",")
 	if len(intervals) < 2 || len(intervals) > 3 {
@@ -70,58 +238,31 @@ func ParseInterval(data string) (result Interval, err error) {
 		return
 	}
 
-	processIntervalValue := func(serialized string, i *IntervalValue) error {
-		r := regexp.MustCompile("([\\d]*)([%kKmMgG]{0,1})").FindStringSubmatch(serialized)
-		if len(r) < 3 {
-			return fmt.Errorf("Invalid interval format for '%s'", serialized)
-		}
-		i.Obsolete = true
-		value, err := strconv.Atoi(r[1])
+	processIntervalValue := func(serialized string, i *IntervalValue, allowNegative bool) error {
+		value, err := parseIntervalValue(serialized, true)
 		if err != nil {
-			return errors.Wrapf(err, "Failed to convert string '%s' to integer", r[1])
-		}
-		if value < 0 {
-			return fmt.Errorf("Invalid interval format for '%s'. Must be positive", serialized)
+			return errors.Wrapf(err, "Invalid interval '%s'", data)
 		}
-		i.Value = int64(value)
-
-		switch r[2] {
-		case "%":
-			i.Obsolete = false
-			if i.Value > 100 {
-				return fmt.Errorf("Invalid interval format for '%s'. Must be in [0;100]", serialized)
-			}
-		case "k":
-			i.Value *= 1000
-		case "K":
-			i.Value *= 1024
-		case "m":
-			i.Value *= 1000 * 1000
-		case "M":
-			i.Value *= 1024 * 1024
-		case "g":
-			i.Value *= 1000 * 1000 * 1000
-		case "G":
-			i.Value *= 1024 * 1024 * 1024
-		case "":
-		default:
-			panic(fmt.Errorf("Invalid postfix in regex"))
+		if value.FromEnd && allowNegative == false {
+			return fmt.Errorf("Invalid interval format for '%s'. Only the first value could be negative",
+				serialized)
 		}
+		*i = value
 		return nil
 	}
 
-	err = processIntervalValue(intervals[0], &result.NotModify)
+	err = processIntervalValue(intervals[0], &result.NotModify, true)
 	if err != nil {
 		return
 	}
 
-	err = processIntervalValue(intervals[1], &result.Modify)
+	err = processIntervalValue(intervals[1], &result.Modify, false)
 	if err != nil {
 		return
 	}
 
 	if len(intervals) > 2 {
-		err = processIntervalValue(intervals[2], &result.NotModifyUntil)
+		err = processIntervalValue(intervals[2], &result.NotModifyUntil, false)
 		if err != nil {
 			return
 		}
@@ -130,35 +271,15 @@ func ParseInterval(data string) (result Interval, err error) {
 	return
 }
 
+// ParseSize parses size in bytes with optional fraction and suffix. For example: '512', '4K',
+// '1.5G', '10MiB'. See getSizeMultiplier for suffixes
 func ParseSize(rawSize string) (uint64, error) {
-	r := regexp.MustCompile("([\\d]*)([kKmMgG]{0,1})").FindStringSubmatch(rawSize)
-
-	if len(r) < 2 {
-		return uint64(0), fmt.Errorf("Invalid size format for '%s'", rawSize)
-	}
-
-	size, err := strconv.ParseInt(r[1], 10, 0)
+	value, err := parseIntervalValue(rawSize, false)
 	if err != nil {
-		return uint64(0), errors.Wrapf(err, "Failed to parse integer '%s'", r[1])
-	}
-
-	switch r[2] {
-	case "k":
-		size *= 1000
-	case "K":
-		size *= 1024
-	case "m":
-		size *= 1000 * 1000
-	case "M":
-		size *= 1024 * 1024
-	case "g":
-		size *= 1000 * 1000 * 1000
-	case "G":
-		size *= 1024 * 1024 * 1024
-	case "":
-	default:
-		panic(fmt.Errorf("Invalid postfix in regex"))
-	}
-
-	return uint64(size), nil
+		return uint64(0), err
+	}
+	if value.FromEnd {
+		return uint64(0), fmt.Errorf("Invalid size '%s'. Must not be negative", rawSize)
+	}
+	return uint64(value.Value), nil
 }
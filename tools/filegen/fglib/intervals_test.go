@@ -9,6 +9,8 @@ package fglib
 
 import (
 	"fmt"
+	"math"
+	"reflect"
 	"testing"
 )
 
@@ -38,13 +40,26 @@ func TestParseObsoleteInterval(t *testing.T) {
 	checkIntervalValue(t, "notModifyUntil", i.NotModifyUntil, 30902, true)
 }
 
-func TestFailOnNegativeInterval(t *testing.T) {
-	_, err := ParseInterval("-8953,480,30902")
-	if err == nil {
-		t.Error(fmt.Errorf("Successfully parse negative interval"))
+func TestParseIntervalFromEnd(t *testing.T) {
+	i, err := ParseInterval("-8953,480,30902")
+	if err != nil {
+		t.Error(err)
+	}
+	if i.NotModify.FromEnd == false {
+		t.Error(fmt.Errorf("First negative value is not counted from the end"))
+	}
+	checkIntervalValue(t, "notModify", GetObsoleteInterval(i, 10000).NotModify, 10000-8953, true)
+	checkIntervalValue(t, "notModify", GetObsoleteInterval(i, 100).NotModify, 0, true)
+
+	i, err = ParseInterval("-10%,5%")
+	if err != nil {
+		t.Error(err)
 	}
+	checkIntervalValue(t, "notModify", GetObsoleteInterval(i, 1000).NotModify, 900, true)
+}
 
-	_, err = ParseInterval("8953,-480,30902")
+func TestFailOnNegativeInterval(t *testing.T) {
+	_, err := ParseInterval("8953,-480,30902")
 	if err == nil {
 		t.Error(fmt.Errorf("Successfully parse negative interval"))
 	}
@@ -56,7 +71,7 @@ func TestFailOnNegativeInterval(t *testing.T) {
 }
 
 func TestParseNotObsoleteInterval(t *testing.T) {
-	i, err := ParseInterval("23%%,10%%,17%%")
+	i, err := ParseInterval("23%,10%,17%")
 	if err != nil {
 		t.Error(err)
 	}
@@ -66,24 +81,24 @@ func TestParseNotObsoleteInterval(t *testing.T) {
 }
 
 func TestFailToParseNotObsoleteIntervalWithInvalidValues(t *testing.T) {
-	_, err := ParseInterval("8953%%,80%%,3%%")
+	_, err := ParseInterval("8953%,80%,3%")
 	if err == nil {
 		t.Error(fmt.Errorf("Successfully parse more then 100%% interval"))
 	}
 
-	_, err = ParseInterval("89%%,840%%,3%%")
+	_, err = ParseInterval("89%,840%,3%")
 	if err == nil {
 		t.Error(fmt.Errorf("Successfully parse more then 100%% interval"))
 	}
 
-	_, err = ParseInterval("89%%,40%%,378%%")
+	_, err = ParseInterval("89%,40%,378%")
 	if err == nil {
 		t.Error(fmt.Errorf("Successfully parse more then 100%% interval"))
 	}
 }
 
 func TestParseCombineInterval(t *testing.T) {
-	i, err := ParseInterval("23K,96%%,17k")
+	i, err := ParseInterval("23K,96%,17k")
 	if err != nil {
 		t.Error(err)
 	}
@@ -91,7 +106,7 @@ func TestParseCombineInterval(t *testing.T) {
 	checkIntervalValue(t, "modify", i.Modify, 96, false)
 	checkIntervalValue(t, "notModifyUntil", i.NotModifyUntil, 17*1000, true)
 
-	i, err = ParseInterval("23%%,96m,17M")
+	i, err = ParseInterval("23%,96m,17M")
 	if err != nil {
 		t.Error(err)
 	}
@@ -99,7 +114,7 @@ func TestParseCombineInterval(t *testing.T) {
 	checkIntervalValue(t, "modify", i.Modify, 96*1000*1000, true)
 	checkIntervalValue(t, "notModifyUntil", i.NotModifyUntil, 17*1024*1024, true)
 
-	i, err = ParseInterval("23g,96G,17%%")
+	i, err = ParseInterval("23g,96G,17%")
 	if err != nil {
 		t.Error(err)
 	}
@@ -108,6 +123,67 @@ func TestParseCombineInterval(t *testing.T) {
 	checkIntervalValue(t, "notModifyUntil", i.NotModifyUntil, 17, false)
 }
 
+func TestParseDecimalInterval(t *testing.T) {
+	i, err := ParseInterval("12.5%,1.5K,0.25%")
+	if err != nil {
+		t.Error(err)
+	}
+	if i.NotModify.Value != 125 || i.NotModify.Decimals != 1 || i.NotModify.Obsolete {
+		t.Errorf("Failed to parse 12.5%%: %+v", i.NotModify)
+	}
+	checkIntervalValue(t, "modify", i.Modify, 1536, true)
+
+	obsolete := GetObsoleteInterval(i, 10000)
+	checkIntervalValue(t, "notModify", obsolete.NotModify, 1250, true)
+	checkIntervalValue(t, "notModifyUntil", obsolete.NotModifyUntil, 25, true)
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		data string
+		size uint64
+	}{
+		{"0", 0},
+		{"512", 512},
+		{"512B", 512},
+		{"4k", 4000},
+		{"4K", 4096},
+		{"1.5G", 3 << 29},
+		{"1.5g", 1500000000},
+		{"2T", 2 << 40},
+		{"2t", 2000000000000},
+		{"3P", 3 << 50},
+		{"10MB", 10000000},
+		{"10mb", 10000000},
+		{"10MiB", 10 << 20},
+		{"1kB", 1000},
+		{"1KiB", 1024},
+		{"0.1K", 102},
+	}
+	for _, test := range tests {
+		size, err := ParseSize(test.data)
+		if err != nil {
+			t.Errorf("Failed to parse '%s': %v", test.data, err)
+		} else if size != test.size {
+			t.Errorf("Parsed '%s' as %d. Must be %d", test.data, size, test.size)
+		}
+	}
+}
+
+func TestFailToParseInvalidSize(t *testing.T) {
+	for _, data := range []string{"", "K", "12x", "4KK", "1.5.5G", ".5G", "5.G", " 5", "5 K", "-4K", "10%",
+		"7EiB", "9000P", "1k2"} {
+		if _, err := ParseSize(data); err == nil {
+			t.Errorf("Successfully parse invalid size '%s'", data)
+		}
+	}
+	for _, data := range []string{"23%%,10%", "1,2,3,4", "1", "10%x,5", "5,100.5%", "5,0.0000000001%"} {
+		if _, err := ParseInterval(data); err == nil {
+			t.Errorf("Successfully parse invalid interval '%s'", data)
+		}
+	}
+}
+
 func TestGetFullInterval(t *testing.T) {
 	i := GetFullInterval()
 	checkIntervalValue(t, "notModify", i.NotModify, 0, true)
@@ -121,3 +197,214 @@ func TestGetEmptyInterval(t *testing.T) {
 	checkIntervalValue(t, "modify", i.Modify, 0, true)
 	checkIntervalValue(t, "notModifyUntil", i.NotModifyUntil, 0, true)
 }
+
+func bytesValue(value int64) IntervalValue {
+	return IntervalValue{Value: value, Obsolete: true}
+}
+
+func percentsValue(value int64) IntervalValue {
+	return IntervalValue{Value: value, Obsolete: false}
+}
+
+// getIntervalRanges returns all ranges walked by WalkIntervalRanges
+func getIntervalRanges(interval Interval, size int64, once, reverse bool) []ByteRange {
+	var ranges []ByteRange
+	WalkIntervalRanges(interval, size, once, reverse, func(r ByteRange) error {
+		ranges = append(ranges, r)
+		return nil
+	})
+	return ranges
+}
+
+func TestWalkIntervalRanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval Interval
+		size     int64
+		once     bool
+		reverse  bool
+		ranges   []ByteRange
+	}{
+		{"full", GetFullInterval(), 100, false, false, []ByteRange{{0, 100}}},
+		{"full reverse", GetFullInterval(), 100, false, true, []ByteRange{{0, 100}}},
+		{"empty", GetEmptyInterval(), 100, false, false, nil},
+		{"empty file", GetFullInterval(), 0, false, false, nil},
+		{"zero modify", Interval{bytesValue(10), bytesValue(0), bytesValue(0)}, 100, false, false, nil},
+		{"repeated", Interval{bytesValue(10), bytesValue(20), bytesValue(0)}, 100, false, false,
+			[]ByteRange{{10, 20}, {40, 20}, {70, 20}}},
+		{"repeated with tail", Interval{bytesValue(10), bytesValue(20), bytesValue(5)}, 100, false, false,
+			[]ByteRange{{10, 20}, {45, 20}, {80, 20}}},
+		{"last range is cut", Interval{bytesValue(0), bytesValue(30), bytesValue(10)}, 100, false, false,
+			[]ByteRange{{0, 30}, {40, 30}, {80, 20}}},
+		{"once", Interval{bytesValue(10), bytesValue(20), bytesValue(5)}, 100, true, false, []ByteRange{{10, 20}}},
+		{"once reverse", Interval{bytesValue(10), bytesValue(20), bytesValue(5)}, 100, true, true, []ByteRange{{70, 20}}},
+		{"reverse", Interval{bytesValue(10), bytesValue(20), bytesValue(5)}, 100, false, true,
+			[]ByteRange{{70, 20}, {35, 20}, {0, 20}}},
+		{"reverse cut at beginning", Interval{bytesValue(0), bytesValue(30), bytesValue(10)}, 100, false, true,
+			[]ByteRange{{70, 30}, {30, 30}, {0, 20}}},
+		{"skip beyond end", Interval{bytesValue(100), bytesValue(20), bytesValue(0)}, 100, false, false, nil},
+		{"skip to last byte", Interval{bytesValue(99), bytesValue(20), bytesValue(0)}, 100, false, false, []ByteRange{{99, 1}}},
+		{"modify more than file", Interval{bytesValue(0), bytesValue(1000), bytesValue(0)}, 100, false, false,
+			[]ByteRange{{0, 100}}},
+		{"percents", Interval{percentsValue(10), percentsValue(20), percentsValue(10)}, 1000, false, false,
+			[]ByteRange{{100, 200}, {500, 200}, {900, 100}}},
+		{"last 10 percents", Interval{percentsValue(0), percentsValue(10), percentsValue(0)}, 1000, true, true,
+			[]ByteRange{{900, 100}}},
+		{"huge skip", Interval{bytesValue(math.MaxInt64), bytesValue(1), bytesValue(math.MaxInt64)}, 1 << 50, false, false, nil},
+		{"huge tail", Interval{bytesValue(0), bytesValue(1), bytesValue(math.MaxInt64)}, 1 << 50, false, false,
+			[]ByteRange{{0, 1}}},
+	}
+
+	for _, test := range tests {
+		ranges := getIntervalRanges(test.interval, test.size, test.once, test.reverse)
+		if reflect.DeepEqual(ranges, test.ranges) == false {
+			t.Errorf("%s: got ranges %v. Must be %v", test.name, ranges, test.ranges)
+		}
+	}
+}
+
+// getModifiedBytes returns map of modified bytes got by applying interval byte by byte
+func getModifiedBytes(i Interval, size int64, once, reverse bool) []bool {
+	i = GetObsoleteInterval(i, size)
+	modified := make([]bool, size)
+	period := i.NotModify.Value + i.Modify.Value + i.NotModifyUntil.Value
+	for distance := int64(0); distance < size && i.Modify.Value > 0; distance++ {
+		if once && distance >= period {
+			break
+		}
+		position := distance % period
+		if position < i.NotModify.Value || position >= i.NotModify.Value+i.Modify.Value {
+			continue
+		}
+		if reverse {
+			modified[size-1-distance] = true
+		} else {
+			modified[distance] = true
+		}
+	}
+	return modified
+}
+
+func FuzzWalkIntervalRanges(f *testing.F) {
+	f.Add(int64(10), int64(20), int64(5), false, int64(100), false, false)
+	f.Add(int64(0), int64(100), int64(0), true, int64(4096), false, true)
+	f.Add(int64(3), int64(7), int64(11), false, int64(1000), true, true)
+	f.Add(int64(30), int64(40), int64(30), true, int64(999), false, false)
+	f.Fuzz(func(t *testing.T, notModify, modify, notModifyUntil int64, percent bool, size int64, once,
+		reverse bool) {
+		/* limit values to check each byte */
+		size = abs(size) % 8192
+		limit := int64(10000)
+		if percent {
+			limit = 101
+		}
+		i := Interval{
+			NotModify:      IntervalValue{Value: abs(notModify) % limit, Obsolete: !percent},
+			Modify:         IntervalValue{Value: abs(modify) % limit, Obsolete: !percent},
+			NotModifyUntil: IntervalValue{Value: abs(notModifyUntil) % limit, Obsolete: !percent},
+		}
+
+		ranges := getIntervalRanges(i, size, once, reverse)
+		if once && len(ranges) > 1 {
+			t.Fatalf("%d ranges for interval used once", len(ranges))
+		}
+		modified := make([]bool, size)
+		obsolete := GetObsoleteInterval(i, size)
+		for j, r := range ranges {
+			if r.Length <= 0 || r.Offset < 0 || r.Offset+r.Length > size {
+				t.Fatalf("Range %v is out of file of %d bytes", r, size)
+			}
+			if r.Length > obsolete.Modify.Value {
+				t.Fatalf("Range %v is longer than %d bytes to modify", r, obsolete.Modify.Value)
+			}
+			if j > 0 && ((reverse == false && r.Offset < ranges[j-1].Offset+ranges[j-1].Length) ||
+				(reverse && r.Offset+r.Length > ranges[j-1].Offset)) {
+				t.Fatalf("Range %v overlaps or is out of order with %v", r, ranges[j-1])
+			}
+			for k := r.Offset; k < r.Offset+r.Length; k++ {
+				modified[k] = true
+			}
+		}
+
+		expected := getModifiedBytes(i, size, once, reverse)
+		if reflect.DeepEqual(modified, expected) == false {
+			t.Fatalf("Ranges %v differ from expected modification of interval %v", ranges, obsolete)
+		}
+	})
+}
+
+func abs(value int64) int64 {
+	if value < 0 {
+		if value == math.MinInt64 {
+			return math.MaxInt64
+		}
+		return -value
+	}
+	return value
+}
+
+func TestGetObsoleteIntervalOfHugeFile(t *testing.T) {
+	tests := []struct {
+		value    IntervalValue
+		size     int64
+		expected int64
+	}{
+		{IntervalValue{Value: 999999999, Decimals: 7}, 1 << 41, 2199023253352},
+		{IntervalValue{Value: 333333333, Decimals: 7}, math.MaxInt64, 3074457342543801256},
+		{IntervalValue{Value: 125, Decimals: 1}, 3 << 40, 412316860416},
+		{IntervalValue{Value: 1, Decimals: 9}, 1 << 50, 11258},
+		{percentsValue(100), math.MaxInt64, math.MaxInt64},
+		{percentsValue(50), 1 << 62, 1 << 61},
+		{IntervalValue{Value: 1, Decimals: 9, FromEnd: true}, 1 << 50, 1<<50 - 11258},
+	}
+	for _, test := range tests {
+		i := GetObsoleteInterval(Interval{Modify: test.value}, test.size)
+		checkIntervalValue(t, fmt.Sprintf("%+v of %d", test.value, test.size), i.Modify, test.expected, true)
+	}
+}
+
+func TestGetIntervalRangesOfHugeFile(t *testing.T) {
+	size := int64(5 << 40)
+	interval, err := ParseInterval("10%,10%,30%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p10, p30 := size/10, size*3/10
+	expected := []ByteRange{{p10, p10}, {p10*2 + p30 + p10, p10}}
+	ranges := getIntervalRanges(interval, size, false, false)
+	if reflect.DeepEqual(ranges, expected) == false {
+		t.Errorf("Got ranges %v. Must be %v", ranges, expected)
+	}
+
+	interval, err = ParseInterval("-1.5P,1T")
+	if err != nil {
+		t.Fatal(err)
+	}
+	size = 2 << 50
+	expected = []ByteRange{{size - 3<<49, 1 << 40}}
+	ranges = getIntervalRanges(interval, size, true, false)
+	if reflect.DeepEqual(ranges, expected) == false {
+		t.Errorf("Got ranges %v. Must be %v", ranges, expected)
+	}
+}
+
+// TestStopWalkIntervalRanges checks that error of walk stops walking of ranges which count is too
+// large to store them
+func TestStopWalkIntervalRanges(t *testing.T) {
+	stop := fmt.Errorf("stop")
+	count := 0
+	err := WalkIntervalRanges(Interval{bytesValue(0), bytesValue(1), bytesValue(1)}, 1<<40, false, false,
+		func(r ByteRange) error {
+			if r.Offset != int64(count)*2 || r.Length != 1 {
+				t.Fatalf("Got range %v at step %d", r, count)
+			}
+			count++
+			if count == 1000 {
+				return stop
+			}
+			return nil
+		})
+	if err != stop || count != 1000 {
+		t.Errorf("Walking is not stopped by error: %v after %d ranges", err, count)
+	}
+}
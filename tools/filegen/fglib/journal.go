@@ -0,0 +1,89 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Journal of ranges written by files modifier to check differential backups
+*/
+
+package fglib
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// JournalEntry describes written range of file. Path is relative to processing folder. Data of
+// range is data of generator stream from GeneratorOffset
+type JournalEntry struct {
+	Path            string `json:"path"`
+	Offset          int64  `json:"offset"`
+	Length          int64  `json:"length"`
+	Generator       string `json:"generator,omitempty"` // name of seeded generator
+	Seed            []byte `json:"seed,omitempty"`      // seed of seeded generator
+	GeneratorOffset uint64 `json:"generator_offset"`
+}
+
+// Journal stores entries as JSON lines. Entries are appended to existing journal
+type Journal struct {
+	file      *os.File
+	writer    *bufio.Writer
+	encoder   *json.Encoder
+	generator string
+	seed      []byte
+	guard     sync.Mutex
+}
+
+// Add adds entry with generator and seed of journal
+func (j *Journal) Add(entry JournalEntry) error {
+	j.guard.Lock()
+	defer j.guard.Unlock()
+	entry.Generator, entry.Seed = j.generator, j.seed
+	return errors.Wrap(j.encoder.Encode(entry), "Failed to write journal entry")
+}
+
+func (j *Journal) Close() error {
+	j.guard.Lock()
+	defer j.guard.Unlock()
+	err := j.writer.Flush()
+	if err != nil {
+		j.file.Close()
+		return errors.Wrap(err, "Failed to flush journal")
+	}
+	return j.file.Close()
+}
+
+// CreateJournal creates journal for data generated by generator with seed. Generator and seed are
+// not stored if seed is nil
+func CreateJournal(path string, generator string, seed []byte) (*Journal, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to open journal '%s'", path)
+	}
+	j := &Journal{
+		file:   file,
+		writer: bufio.NewWriter(file),
+		seed:   seed,
+	}
+	if seed != nil {
+		j.generator = generator
+	}
+	j.encoder = json.NewEncoder(j.writer)
+	return j, nil
+}
+
+// countingGenerator counts bytes read from generator to get offset in generator stream
+type countingGenerator struct {
+	DataGenerator
+	read uint64
+}
+
+func (g *countingGenerator) Read(p []byte) (int, error) {
+	n, err := g.DataGenerator.Read(p)
+	atomic.AddUint64(&g.read, uint64(n))
+	return n, err
+}
@@ -0,0 +1,62 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Manifest of generated attributes to check restored files
+*/
+
+package fglib
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ManifestEntry describes attributes of file. Path is relative to processing folder
+type ManifestEntry struct {
+	Path   string            `json:"path"`
+	Xattrs map[string][]byte `json:"xattrs,omitempty"` // values are encoded in base64
+	ACL    string            `json:"acl,omitempty"`    // in short text form
+}
+
+// Manifest stores entries as JSON lines. Entries are appended to existing manifest
+type Manifest struct {
+	file    *os.File
+	writer  *bufio.Writer
+	encoder *json.Encoder
+	guard   sync.Mutex
+}
+
+func (m *Manifest) Add(entry ManifestEntry) error {
+	m.guard.Lock()
+	defer m.guard.Unlock()
+	return errors.Wrap(m.encoder.Encode(entry), "Failed to write manifest entry")
+}
+
+func (m *Manifest) Close() error {
+	m.guard.Lock()
+	defer m.guard.Unlock()
+	err := m.writer.Flush()
+	if err != nil {
+		m.file.Close()
+		return errors.Wrap(err, "Failed to flush manifest")
+	}
+	return m.file.Close()
+}
+
+func CreateManifest(path string) (*Manifest, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to open manifest '%s'", path)
+	}
+	m := &Manifest{
+		file:   file,
+		writer: bufio.NewWriter(file),
+	}
+	m.encoder = json.NewEncoder(m.writer)
+	return m, nil
+}
@@ -0,0 +1,185 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Names generators for files and directories
+*/
+
+package fglib
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Maximum length of file name in bytes for the most of file systems
+const NameMax = 255
+
+// Characters sets for random names
+const (
+	NameCharsAlnum   = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	NameCharsUnicode = NameCharsAlnum + "äöüßéèçñøåæœ" + "абвгдеёжзийклмнопрстуфхцчшщъыьэюя" +
+		"αβγδεζηθλμξπσφψω" + "日本語中文字漢한국어" + "😀🚀🌍💾"
+	NameCharsSpecial = NameCharsAlnum + " !#$%&'()+,;=@[]^_`{}~-"
+)
+
+type NameGenerator interface {
+	GetName(index uint) (string, error)
+}
+
+/* Index name generator implementation */
+
+type indexNameGenerator struct {
+}
+
+func (ng *indexNameGenerator) GetName(index uint) (string, error) {
+	return fmt.Sprintf("%d", index), nil
+}
+
+func CreateIndexNameGenerator() NameGenerator {
+	return &indexNameGenerator{}
+}
+
+/* Name generator adding prefix and suffix to names */
+
+type affixNameGenerator struct {
+	names  NameGenerator
+	prefix string
+	suffix string
+}
+
+func (ng *affixNameGenerator) GetName(index uint) (string, error) {
+	name, err := ng.names.GetName(index)
+	if err != nil {
+		return "", err
+	}
+	return ng.prefix + name + ng.suffix, nil
+}
+
+func CreateAffixNameGenerator(names NameGenerator, prefix, suffix string) NameGenerator {
+	return &affixNameGenerator{
+		names:  names,
+		prefix: prefix,
+		suffix: suffix,
+	}
+}
+
+func CreatePrefixNameGenerator(prefix string) NameGenerator {
+	return CreateAffixNameGenerator(CreateIndexNameGenerator(), prefix, "")
+}
+
+/* Random names generator implementation */
+
+// getIndexRandom returns random data depending on seed and index only
+func getIndexRandom(seed []byte, index uint, size int) []byte {
+	data := make([]byte, 0, size+sha256.Size)
+	counter := make([]byte, 16)
+	binary.LittleEndian.PutUint64(counter, uint64(index))
+	for block := uint64(0); len(data) < size; block++ {
+		binary.LittleEndian.PutUint64(counter[8:], block)
+		hash := sha256.New()
+		hash.Write(seed)
+		hash.Write(counter)
+		data = hash.Sum(data)
+	}
+	return data[:size]
+}
+
+type randomNameGenerator struct {
+	seed   []byte
+	length int // in bytes
+	chars  []rune
+	single []rune // single byte characters to fill name up to length
+}
+
+func (ng *randomNameGenerator) GetName(index uint) (string, error) {
+	random := getIndexRandom(ng.seed, index, ng.length*4)
+	name := make([]byte, 0, ng.length)
+	for len(name) < ng.length {
+		v := binary.LittleEndian.Uint32(random)
+		random = random[4:]
+		r := ng.chars[int(v%uint32(len(ng.chars)))]
+		if len(name)+utf8.RuneLen(r) > ng.length {
+			r = ng.single[int(v%uint32(len(ng.single)))]
+		}
+		name = append(name, string(r)...)
+	}
+	return string(name), nil
+}
+
+// CreateRandomNameGenerator creates generator of names with length in bytes.
+// Names could be the same for different indexes if length is small.
+func CreateRandomNameGenerator(seed []byte, length int, chars string) (NameGenerator, error) {
+	if length <= 0 || length > NameMax {
+		return nil, fmt.Errorf("Name length must be in [1;%d]. Got: %d", NameMax, length)
+	}
+	if strings.ContainsAny(chars, "/\x00.") {
+		return nil, fmt.Errorf("Name characters cannot contain '/', '.' or null character")
+	}
+
+	ng := &randomNameGenerator{
+		seed:   seed,
+		length: length,
+		chars:  []rune(chars),
+	}
+	for _, r := range ng.chars {
+		if utf8.RuneLen(r) == 1 {
+			ng.single = append(ng.single, r)
+		}
+	}
+	if len(ng.single) == 0 {
+		return nil, fmt.Errorf("Name characters must contain at least one ASCII character")
+	}
+	return ng, nil
+}
+
+/* UUID names generator implementation */
+
+type uuidNameGenerator struct {
+	seed []byte
+}
+
+func (ng *uuidNameGenerator) GetName(index uint) (string, error) {
+	u := getIndexRandom(ng.seed, index, 16)
+	u[6] = (u[6] & 0x0f) | 0x40 // version 4
+	u[8] = (u[8] & 0x3f) | 0x80 // variant RFC 4122
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:]), nil
+}
+
+func CreateUUIDNameGenerator(seed []byte) NameGenerator {
+	return &uuidNameGenerator{seed: seed}
+}
+
+/* Nested names generator to create deep paths */
+
+type nestedNameGenerator struct {
+	names NameGenerator
+	depth uint
+}
+
+func (ng *nestedNameGenerator) GetName(index uint) (string, error) {
+	parts := make([]string, ng.depth)
+	for i := uint(0); i < ng.depth; i++ {
+		name, err := ng.names.GetName(index*ng.depth + i)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = name
+	}
+	return filepath.Join(parts...), nil
+}
+
+// CreateNestedNameGenerator creates generator of paths with depth names in each
+func CreateNestedNameGenerator(names NameGenerator, depth uint) NameGenerator {
+	if depth <= 1 {
+		return names
+	}
+	return &nestedNameGenerator{
+		names: names,
+		depth: depth,
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Tests for names generators
+*/
+
+package fglib
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestIndexNames(t *testing.T) {
+	tests := []struct {
+		names NameGenerator
+		index uint
+		name  string
+	}{
+		{CreateIndexNameGenerator(), 0, "0"},
+		{CreateIndexNameGenerator(), 12345, "12345"},
+		{CreatePrefixNameGenerator("dir_"), 7, "dir_7"},
+		{CreateAffixNameGenerator(CreateIndexNameGenerator(), "f_", ".txt"), 3, "f_3.txt"},
+		{CreateNestedNameGenerator(CreatePrefixNameGenerator("d"), 1), 2, "d2"},
+		{CreateNestedNameGenerator(CreatePrefixNameGenerator("d"), 3), 2, filepath.Join("d6", "d7", "d8")},
+	}
+	for _, test := range tests {
+		name, err := test.names.GetName(test.index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != test.name {
+			t.Errorf("Name with index %d is '%s' instead of '%s'", test.index, name, test.name)
+		}
+	}
+}
+
+func TestRandomNames(t *testing.T) {
+	tests := []struct {
+		length int
+		chars  string
+	}{
+		{1, NameCharsAlnum},
+		{16, NameCharsAlnum},
+		{NameMax, NameCharsAlnum},
+		{3, NameCharsUnicode},
+		{16, NameCharsUnicode},
+		{NameMax, NameCharsUnicode},
+		{16, NameCharsSpecial},
+	}
+	for _, test := range tests {
+		names, err := CreateRandomNameGenerator(SeedFromUint64(1), test.length, test.chars)
+		if err != nil {
+			t.Fatal(err)
+		}
+		other, err := CreateRandomNameGenerator(SeedFromUint64(2), test.length, test.chars)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for index := uint(0); index < 100; index++ {
+			name, err := names.GetName(index)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(name) != test.length || utf8.ValidString(name) == false {
+				t.Fatalf("Name '%s' has %d bytes instead of %d or it is not valid UTF-8", name, len(name), test.length)
+			}
+			for _, r := range name {
+				if strings.ContainsRune(test.chars, r) == false {
+					t.Fatalf("Name '%s' has character '%c' not from set", name, r)
+				}
+			}
+			again, _ := names.GetName(index)
+			if again != name {
+				t.Fatalf("Names with index %d differ for the same seed: '%s' and '%s'", index, name, again)
+			}
+			if otherName, _ := other.GetName(index); test.length >= 16 && otherName == name {
+				t.Fatalf("Names with index %d are the same for different seeds: '%s'", index, name)
+			}
+		}
+	}
+}
+
+func TestRandomNamesErrors(t *testing.T) {
+	tests := []struct {
+		length int
+		chars  string
+	}{
+		{0, NameCharsAlnum},
+		{NameMax + 1, NameCharsAlnum},
+		{16, "ab/"},
+		{16, "ab."},
+		{16, "日本語"},
+	}
+	for _, test := range tests {
+		_, err := CreateRandomNameGenerator(SeedFromUint64(1), test.length, test.chars)
+		if err == nil {
+			t.Errorf("Generator of names with length %d from '%s' is created", test.length, test.chars)
+		}
+	}
+}
+
+func TestUUIDNames(t *testing.T) {
+	uuid := regexp.MustCompile("^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$")
+	names := CreateUUIDNameGenerator(SeedFromUint64(1))
+	seen := make(map[string]bool)
+	for index := uint(0); index < 100; index++ {
+		name, err := names.GetName(index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uuid.MatchString(name) == false {
+			t.Fatalf("Name '%s' is not UUID version 4", name)
+		}
+		if seen[name] {
+			t.Fatalf("UUID '%s' is repeated", name)
+		}
+		seen[name] = true
+	}
+}
+
+// TestNamesLength checks validation of names length with prefixes and suffixes
+func TestNamesLength(t *testing.T) {
+	long := strings.Repeat("p", 200)
+	tests := []struct {
+		dirNames   string
+		fileNames  string
+		nameLength string
+		dirPrefix  string
+		filePrefix string
+		fileSuffix string
+		fileExt    string
+		fileTypes  string
+		valid      bool
+	}{
+		{"random", "random", "16", "dir_", "file_", "", "", "", true},
+		{"random", "index", "255", "", "file_", "", "", "", true},
+		{"random", "index", "255", "d", "", "", "", "", false},
+		{"index", "random", "49", "", long, "_s", ".txt", "", true},
+		{"index", "random", "50", "", long, "_s", ".txt", "", false},
+		{"index", "random", "max", "", long, "", "", ".jpeg:1,.t:1", true},
+		{"index", "random", "51", "", long, "", "", ".jpeg:1,.t:1", false},
+		{"index", "uuid", "16", "", long, "", "", "", true},
+		{"uuid", "index", "16", long + strings.Repeat("p", 20), "", "", "", "", false},
+		{"index", "random", "max", "", strings.Repeat("p", NameMax), "", "", "", false},
+		{"index", "index", "0", "", "", "", "", "", false},
+	}
+	for _, test := range tests {
+		o := &CmdOptions{Command: CommandGenerate, Seed: SeedFromUint64(1)}
+		o.Names.DirTypeName, o.Names.FileTypeName = test.dirNames, test.fileNames
+		o.Names.DirPrefix, o.Names.FilePrefix = test.dirPrefix, test.filePrefix
+		o.Names.FileSuffix, o.Names.FileExt = test.fileSuffix, test.fileExt
+		o.Names.Chars = "alnum"
+		o.Names.Depth = 1
+		err := o.processNames(test.nameLength, test.fileTypes)
+		if (err == nil) != test.valid {
+			t.Errorf("Names %+v with length '%s' are processed with error: %v", o.Names, test.nameLength, err)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Options of files generator and modifier
+*/
+
+package fglib
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Progress describes state of processing. Total is 0 if it is unknown yet
+type Progress struct {
+	Processed uint64
+	Total     uint64
+	Completed bool // true for the last report even if processing is failed or canceled
+}
+
+type ProgressFunc func(progress Progress)
+
+// Components which options are applied to. Constructors fail with ErrNotSupported for options which
+// their component ignores
+const (
+	componentGenerator = 1 << iota
+	componentArchive
+	componentModifier
+	componentWorkload
+	componentReader
+	componentStreamer
+	componentDiffer
+	componentBench
+
+	componentAll = componentGenerator | componentArchive | componentModifier | componentWorkload |
+		componentReader | componentStreamer | componentDiffer | componentBench
+)
+
+type options struct {
+	component        int
+	unsupported      []string // names of options ignored by component
+	dirNames         NameGenerator
+	fileNames        NameGenerator
+	checkpoint       *Checkpoint
+	special          *SpecialFiles
+	attrs            *Attributes
+	perms            *Permissions
+	progress         ProgressFunc
+	progressInterval time.Duration
+	verify           FileDataGenerator
+	offsetsSeed      []byte
+	selectSeed       []byte
+	selectCount      uint64
+	filter           *FileFilter
+	skew             *Skew
+	hotSeed          []byte
+	journal          *Journal
+}
+
+// Option changes default behaviour of files generator and modifier. Constructors return
+// ErrNotSupported for options they do not honor
+type Option func(o *options)
+
+// option creates Option which is applied only to components
+func option(name string, components int, apply func(o *options)) Option {
+	return func(o *options) {
+		if o.component&components == 0 {
+			o.unsupported = append(o.unsupported, name)
+			return
+		}
+		apply(o)
+	}
+}
+
+// WithDirNames sets names of directories. Names are 'dir_N' by default. Honored by
+// CreateLinearFileGenerator and CreateArchiveFileGenerator
+func WithDirNames(names NameGenerator) Option {
+	return option("WithDirNames", componentGenerator|componentArchive, func(o *options) {
+		o.dirNames = names
+	})
+}
+
+// WithFileNames sets names of files. Names are 'file_N' by default. Honored by
+// CreateLinearFileGenerator and CreateArchiveFileGenerator
+func WithFileNames(names NameGenerator) Option {
+	return option("WithFileNames", componentGenerator|componentArchive, func(o *options) {
+		o.fileNames = names
+	})
+}
+
+// WithCheckpoint sets checkpoint to store progress in. Generation is started from position of
+// checkpoint. Honored by CreateLinearFileGenerator
+func WithCheckpoint(checkpoint *Checkpoint) Option {
+	return option("WithCheckpoint", componentGenerator, func(o *options) {
+		o.checkpoint = checkpoint
+	})
+}
+
+// WithSpecialFiles creates links and special files instead of some regular files. Honored by
+// CreateLinearFileGenerator and CreateArchiveFileGenerator
+func WithSpecialFiles(special *SpecialFiles) Option {
+	return option("WithSpecialFiles", componentGenerator|componentArchive, func(o *options) {
+		o.special = special
+	})
+}
+
+// WithAttributes sets extended attributes and ACL to generated directories and files or to changed
+// files. Honored by CreateLinearFileGenerator and CreateFilesModifierWithInterval
+func WithAttributes(attrs *Attributes) Option {
+	return option("WithAttributes", componentGenerator|componentModifier, func(o *options) {
+		o.attrs = attrs
+	})
+}
+
+// WithPermissions sets modes and owners to generated directories and files. Honored by
+// CreateLinearFileGenerator and CreateArchiveFileGenerator
+func WithPermissions(perms *Permissions) Option {
+	return option("WithPermissions", componentGenerator|componentArchive, func(o *options) {
+		o.perms = perms
+	})
+}
+
+// WithProgress sets callback to be called with interval and on completion. Progress is not reported
+// by default. Honored by all constructors
+func WithProgress(callback ProgressFunc, interval time.Duration) Option {
+	return option("WithProgress", componentAll, func(o *options) {
+		o.progress = callback
+		o.progressInterval = interval
+	})
+}
+
+// WithVerification compares read data with data of files generated by gen. Honored by CreateFilesReader.
+// Only pseudo generator can generate data at offset, so reading fails with other generators
+func WithVerification(gen FileDataGenerator) Option {
+	return option("WithVerification", componentReader, func(o *options) {
+		o.verify = gen
+	})
+}
+
+// WithOffsetsSeed reads the same random offsets of file for the same seed and path. Offsets are
+// seeded with time by default. Honored by CreateFilesReader
+func WithOffsetsSeed(seed []byte) Option {
+	return option("WithOffsetsSeed", componentReader, func(o *options) {
+		o.offsetsSeed = seed
+	})
+}
+
+// WithSelectionSeed selects the same files to change for the same seed and tree. Files are selected
+// with data generator by default. Honored by CreateFilesModifierWithInterval and CreateWorkload
+func WithSelectionSeed(seed []byte) Option {
+	return option("WithSelectionSeed", componentModifier|componentWorkload, func(o *options) {
+		o.selectSeed = seed
+	})
+}
+
+// WithSelectedCount sets exact count of files to change instead of ratio. Honored by
+// CreateFilesModifierWithInterval and CreateWorkload
+func WithSelectedCount(count uint64) Option {
+	return option("WithSelectedCount", componentModifier|componentWorkload, func(o *options) {
+		o.selectCount = count
+	})
+}
+
+// WithFilter changes only files matched by filter. Honored by CreateFilesModifierWithInterval and
+// CreateWorkload
+func WithFilter(filter *FileFilter) Option {
+	return option("WithFilter", componentModifier|componentWorkload, func(o *options) {
+		o.filter = filter
+	})
+}
+
+// WithSkew selects hot files more often than cold ones. The same files are hot for the same hotSeed.
+// Honored by CreateFilesModifierWithInterval and CreateWorkload
+func WithSkew(skew *Skew, hotSeed []byte) Option {
+	return option("WithSkew", componentModifier|componentWorkload, func(o *options) {
+		o.skew = skew
+		o.hotSeed = hotSeed
+	})
+}
+
+// WithJournal stores written ranges of files to journal. Honored by CreateFilesModifierWithInterval
+func WithJournal(journal *Journal) Option {
+	return option("WithJournal", componentModifier, func(o *options) {
+		o.journal = journal
+	})
+}
+
+// getOptions applies opts to options of component. Error is returned if some options are not
+// supported by component
+func getOptions(component int, opts []Option) (options, error) {
+	o := options{
+		component:        component,
+		dirNames:         CreatePrefixNameGenerator("dir_"),
+		fileNames:        CreatePrefixNameGenerator("file_"),
+		progressInterval: time.Second,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if len(o.unsupported) > 0 {
+		return o, errors.Wrapf(ErrNotSupported, "Options %s are not supported",
+			strings.Join(o.unsupported, ", "))
+	}
+	return o, nil
+}
+
+func (o *options) reportProgress(progress Progress) {
+	if o.progress != nil {
+		o.progress(progress)
+	}
+}
+
+// runWithProgress runs work in goroutine and reports progress with interval until work is completed
+func (o *options) runWithProgress(work func() error, getProgress func() Progress) error {
+	completed := make(chan error, 1)
+	go func() {
+		completed <- work()
+	}()
+
+	timeout := time.NewTicker(o.progressInterval)
+	defer timeout.Stop()
+	for {
+		select {
+		case <-timeout.C:
+			o.reportProgress(getProgress())
+		case err := <-completed:
+			progress := getProgress()
+			progress.Completed = true
+			o.reportProgress(progress)
+			return err
+		}
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Tests for options of constructors
+*/
+
+package fglib
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TestUnsupportedOptions checks that constructors fail with options they do not honor
+func TestUnsupportedOptions(t *testing.T) {
+	mix, err := ParseOperationsMix("stat:100%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen := CreateNullDataGenerator()
+	constructors := map[string]func(opts []Option) error{
+		"generator": func(opts []Option) error {
+			_, err := CreateLinearFileGenerator(gen, "", 1, 1, 1, opts...)
+			return err
+		},
+		"archive": func(opts []Option) error {
+			_, err := CreateArchiveFileGenerator(gen, io.Discard, ArchiveTar, 1, 1, 1, opts...)
+			return err
+		},
+		"modifier": func(opts []Option) error {
+			_, err := CreateFilesModifierWithInterval(gen, "", 1, Interval{}, true, false, opts...)
+			return err
+		},
+		"workload": func(opts []Option) error {
+			_, err := CreateWorkload(gen, "", 1, mix, time.Second, 1, 1, 1, Interval{}, true, false, opts...)
+			return err
+		},
+		"reader": func(opts []Option) error {
+			_, err := CreateFilesReader("", ReadSequential, 1, 1, opts...)
+			return err
+		},
+		"streamer": func(opts []Option) error {
+			_, err := CreateDataStreamer(gen, io.Discard, 1, opts...)
+			return err
+		},
+		"differ": func(opts []Option) error {
+			_, err := CreateTreeDiffer("", "", 1, opts...)
+			return err
+		},
+		"bench": func(opts []Option) error {
+			_, err := CreateGeneratorBench(time.Second, 1, opts...)
+			return err
+		},
+	}
+
+	tests := []struct {
+		option    Option
+		supported []string
+	}{
+		{WithDirNames(CreatePrefixNameGenerator("d")), []string{"generator", "archive"}},
+		{WithFileNames(CreatePrefixNameGenerator("f")), []string{"generator", "archive"}},
+		{WithCheckpoint(CreateCheckpoint("", GeneratorPseudo, nil, "", 1, 1, 1)), []string{"generator"}},
+		{WithSpecialFiles(&SpecialFiles{}), []string{"generator", "archive"}},
+		{WithAttributes(&Attributes{}), []string{"generator", "modifier"}},
+		{WithPermissions(&Permissions{}), []string{"generator", "archive"}},
+		{WithProgress(func(Progress) {}, time.Second), []string{"generator", "archive", "modifier",
+			"workload", "reader", "streamer", "differ", "bench"}},
+		{WithVerification(nil), []string{"reader"}},
+		{WithOffsetsSeed(SeedFromUint64(1)), []string{"reader"}},
+		{WithSelectionSeed(SeedFromUint64(1)), []string{"modifier", "workload"}},
+		{WithSelectedCount(1), []string{"modifier", "workload"}},
+		{WithFilter(&FileFilter{}), []string{"modifier", "workload"}},
+		{WithSkew(&Skew{}, nil), []string{"modifier", "workload"}},
+		{WithJournal(&Journal{}), []string{"modifier"}},
+	}
+	for i, test := range tests {
+		supported := make(map[string]bool)
+		for _, name := range test.supported {
+			supported[name] = true
+		}
+		for name, create := range constructors {
+			err := create([]Option{test.option})
+			if supported[name] && err != nil {
+				t.Errorf("Option %d is not supported by %s: %v", i, name, err)
+			}
+			if supported[name] == false && errors.Cause(err) != ErrNotSupported {
+				t.Errorf("Option %d is accepted by %s with error: %v", i, name, err)
+			}
+		}
+	}
+}
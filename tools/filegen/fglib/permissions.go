@@ -0,0 +1,141 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Permissions and ownership of generated files
+*/
+
+package fglib
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// FileModeFromUnix converts mode in Unix format (for example, 04755) to os.FileMode
+func FileModeFromUnix(mode uint32) os.FileMode {
+	result := os.FileMode(mode & 0777)
+	if mode&04000 != 0 {
+		result |= os.ModeSetuid
+	}
+	if mode&02000 != 0 {
+		result |= os.ModeSetgid
+	}
+	if mode&01000 != 0 {
+		result |= os.ModeSticky
+	}
+	return result
+}
+
+// Modes used for random permissions. They include special bits and modes without access
+var (
+	RandomFileModes = []uint32{0644, 0600, 0640, 0664, 0666, 0444, 0400, 0200, 0000,
+		0755, 0700, 04755, 02755, 06755}
+	RandomDirModes = []uint32{0755, 0750, 0700, 0775, 0555, 0500, 0311, 0000, 01777, 02775}
+)
+
+// IDRange is range [Min;Max] of user or group ids. It is not used if Min is negative
+type IDRange struct {
+	Min int
+	Max int
+}
+
+// Permissions sets modes and owners to files and directories. Random values depend on seed
+// and relative path only
+type Permissions struct {
+	seed      []byte
+	fileModes []os.FileMode // mode is not changed if empty
+	dirModes  []os.FileMode
+	uids      IDRange
+	gids      IDRange
+}
+
+func (p *Permissions) getRandom(name string) func(i int, n int) int {
+	random := getIndexRandom(append(append([]byte{}, p.seed...), name...), 0, 24)
+	return func(i int, n int) int {
+		return int(binary.LittleEndian.Uint64(random[i*8:]) % uint64(n))
+	}
+}
+
+// getOwner returns user and group ids for name. Id is -1 if it is not changed
+func (p *Permissions) getOwner(name string) (int, int) {
+	getRandom := p.getRandom(name)
+	uid, gid := -1, -1
+	if p.uids.Min >= 0 {
+		uid = p.uids.Min + getRandom(0, p.uids.Max-p.uids.Min+1)
+	}
+	if p.gids.Min >= 0 {
+		gid = p.gids.Min + getRandom(1, p.gids.Max-p.gids.Min+1)
+	}
+	return uid, gid
+}
+
+// getMode returns mode from modes for name. It returns false if modes are empty
+func (p *Permissions) getMode(name string, modes []os.FileMode) (os.FileMode, bool) {
+	if len(modes) == 0 {
+		return 0, false
+	}
+	return modes[p.getRandom(name)(2, len(modes))], true
+}
+
+func (p *Permissions) apply(path string, name string, modes []os.FileMode) error {
+	/* owner is changed before mode because chown resets setuid and setgid bits */
+	if p.uids.Min >= 0 || p.gids.Min >= 0 {
+		uid, gid := p.getOwner(name)
+		err := os.Lchown(path, uid, gid)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to change owner of '%s'", path)
+		}
+	}
+
+	if mode, ok := p.getMode(name, modes); ok {
+		err := os.Chmod(path, mode)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to change mode of '%s'", path)
+		}
+	}
+	return nil
+}
+
+func (p *Permissions) ApplyFile(path string, name string) error {
+	return p.apply(path, name, p.fileModes)
+}
+
+// ApplyDir should be called after files and hard links to them are created because mode could
+// deny it
+func (p *Permissions) ApplyDir(path string, name string) error {
+	return p.apply(path, name, p.dirModes)
+}
+
+// CreatePermissions creates permissions generator. Random mode from fileModes or dirModes is set.
+// Modes are in Unix format
+func CreatePermissions(seed []byte, fileModes []uint32, dirModes []uint32, uids IDRange,
+	gids IDRange) (*Permissions, error) {
+	p := &Permissions{
+		seed: seed,
+		uids: uids,
+		gids: gids,
+	}
+
+	for _, mode := range fileModes {
+		if mode > 07777 {
+			return nil, fmt.Errorf("Invalid file mode %o", mode)
+		}
+		p.fileModes = append(p.fileModes, FileModeFromUnix(mode))
+	}
+	for _, mode := range dirModes {
+		if mode > 07777 {
+			return nil, fmt.Errorf("Invalid directory mode %o", mode)
+		}
+		p.dirModes = append(p.dirModes, FileModeFromUnix(mode))
+	}
+	for _, r := range []IDRange{uids, gids} {
+		if r.Min >= 0 && r.Max < r.Min {
+			return nil, fmt.Errorf("Invalid ids range [%d;%d]", r.Min, r.Max)
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,174 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Reporters of processing progress
+*/
+
+package fglib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// ProgressEnum
+const (
+	ProgressTTY = iota
+	ProgressPlain
+	ProgressJSON
+	ProgressSilent
+)
+
+// ProgressReporter shows progress of generation or modification
+type ProgressReporter interface {
+	Report(progress Progress)
+}
+
+// WithProgressReporter sets reporter to be called with interval and on completion
+func WithProgressReporter(reporter ProgressReporter, interval time.Duration) Option {
+	return WithProgress(reporter.Report, interval)
+}
+
+func getPercent(progress Progress) uint64 {
+	if progress.Total == 0 {
+		return 0
+	}
+	return progress.Processed * 100 / progress.Total
+}
+
+/* Progress bar on the same line of terminal */
+
+type ttyProgressReporter struct {
+	writer io.Writer
+	title  string
+	width  int
+}
+
+func (r *ttyProgressReporter) Report(progress Progress) {
+	if progress.Total == 0 {
+		fmt.Fprintf(r.writer, "\r%s: %d        ", r.title, progress.Processed)
+	} else {
+		filled := int(getPercent(progress)) * r.width / 100
+		if filled > r.width {
+			filled = r.width // processed could exceed estimated total
+		}
+		bar := strings.Repeat("#", filled) + strings.Repeat(" ", r.width-filled)
+		fmt.Fprintf(r.writer, "\r%s: [%s] %3d%% (%d/%d)  ", r.title, bar, getPercent(progress),
+			progress.Processed, progress.Total)
+	}
+	if progress.Completed {
+		fmt.Fprintln(r.writer)
+	}
+}
+
+func CreateTTYProgressReporter(writer io.Writer, title string) ProgressReporter {
+	return &ttyProgressReporter{
+		writer: writer,
+		title:  title,
+		width:  40,
+	}
+}
+
+/* Log line for each report */
+
+type plainProgressReporter struct {
+	logger *log.Logger
+	title  string
+}
+
+func (r *plainProgressReporter) Report(progress Progress) {
+	status := "in progress"
+	if progress.Completed {
+		status = "completed"
+	}
+	if progress.Total == 0 {
+		r.logger.Printf("%s: %d, %s\n", r.title, progress.Processed, status)
+		return
+	}
+	r.logger.Printf("%s: %d of %d (%d%%), %s\n", r.title, progress.Processed, progress.Total,
+		getPercent(progress), status)
+}
+
+func CreatePlainProgressReporter(writer io.Writer, title string) ProgressReporter {
+	return &plainProgressReporter{
+		logger: log.New(writer, "", log.LstdFlags),
+		title:  title,
+	}
+}
+
+/* JSON event on each line */
+
+type progressEvent struct {
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+	Processed uint64    `json:"processed"`
+	Total     uint64    `json:"total,omitempty"`
+	Completed bool      `json:"completed"`
+}
+
+type jsonProgressReporter struct {
+	encoder   *json.Encoder
+	operation string
+}
+
+func (r *jsonProgressReporter) Report(progress Progress) {
+	err := r.encoder.Encode(progressEvent{
+		Time:      time.Now().UTC(),
+		Operation: r.operation,
+		Processed: progress.Processed,
+		Total:     progress.Total,
+		Completed: progress.Completed,
+	})
+	if err != nil {
+		log.Printf("Failed to report progress: %s\n", err)
+	}
+}
+
+// CreateJSONProgressReporter creates reporter writing events as JSON objects one per line
+func CreateJSONProgressReporter(writer io.Writer, operation string) ProgressReporter {
+	return &jsonProgressReporter{
+		encoder:   json.NewEncoder(writer),
+		operation: operation,
+	}
+}
+
+/* Silent reporter */
+
+type silentProgressReporter struct {
+}
+
+func (r *silentProgressReporter) Report(progress Progress) {
+}
+
+func CreateSilentProgressReporter() ProgressReporter {
+	return &silentProgressReporter{}
+}
+
+// CreateProgressReporter creates reporter of ProgressEnum type. Progress bar and plain lines are
+// written to output with title, JSON events are written to stderr with operation
+func CreateProgressReporter(reporterType int, output io.Writer, title string, operation string) ProgressReporter {
+	switch reporterType {
+	case ProgressTTY:
+		return CreateTTYProgressReporter(output, title)
+	case ProgressPlain:
+		return CreatePlainProgressReporter(output, title)
+	case ProgressJSON:
+		return CreateJSONProgressReporter(os.Stderr, operation)
+	}
+	return CreateSilentProgressReporter()
+}
+
+// isTerminal returns true if file is character device as terminal is
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
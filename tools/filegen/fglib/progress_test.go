@@ -0,0 +1,100 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Tests for reporters of processing progress
+*/
+
+package fglib
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTTYProgress(t *testing.T) {
+	tests := []struct {
+		progress Progress
+		output   string
+	}{
+		{Progress{Processed: 0, Total: 10}, "\rGenerate: [" + strings.Repeat(" ", 40) + "]   0% (0/10)  "},
+		{Progress{Processed: 5, Total: 10}, "\rGenerate: [" + strings.Repeat("#", 20) + strings.Repeat(" ", 20) +
+			"]  50% (5/10)  "},
+		{Progress{Processed: 10, Total: 10, Completed: true}, "\rGenerate: [" + strings.Repeat("#", 40) +
+			"] 100% (10/10)  \n"},
+		{Progress{Processed: 15, Total: 10}, "\rGenerate: [" + strings.Repeat("#", 40) + "] 150% (15/10)  "},
+		{Progress{Processed: 7}, "\rGenerate: 7        "},
+	}
+	for _, test := range tests {
+		var output bytes.Buffer
+		CreateTTYProgressReporter(&output, "Generate").Report(test.progress)
+		if output.String() != test.output {
+			t.Errorf("Progress %+v is reported as %q instead of %q", test.progress, output.String(), test.output)
+		}
+	}
+}
+
+func TestPlainProgress(t *testing.T) {
+	tests := []struct {
+		progress Progress
+		line     string
+	}{
+		{Progress{Processed: 1, Total: 4}, "Modify: 1 of 4 (25%), in progress"},
+		{Progress{Processed: 4, Total: 4, Completed: true}, "Modify: 4 of 4 (100%), completed"},
+		{Progress{Processed: 3, Completed: true}, "Modify: 3, completed"},
+	}
+	timestamp := regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2} `)
+	for _, test := range tests {
+		var output bytes.Buffer
+		CreatePlainProgressReporter(&output, "Modify").Report(test.progress)
+		line := output.String()
+		if timestamp.MatchString(line) == false || strings.TrimSuffix(timestamp.ReplaceAllString(line, ""),
+			"\n") != test.line {
+			t.Errorf("Progress %+v is reported as %q instead of %q", test.progress, line, test.line)
+		}
+	}
+}
+
+func TestJSONProgress(t *testing.T) {
+	var output bytes.Buffer
+	reporter := CreateJSONProgressReporter(&output, "generate")
+	progress := []Progress{{Processed: 2, Total: 8}, {Processed: 8, Total: 8, Completed: true}, {Processed: 5}}
+	for _, p := range progress {
+		reporter.Report(p)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	if len(lines) != len(progress) {
+		t.Fatalf("%d events are reported instead of %d: %s", len(lines), len(progress), output.String())
+	}
+	for i, line := range lines {
+		var event progressEvent
+		err := json.Unmarshal([]byte(line), &event)
+		if err != nil {
+			t.Fatalf("Failed to parse event '%s': %v", line, err)
+		}
+		if event.Operation != "generate" || event.Processed != progress[i].Processed ||
+			event.Total != progress[i].Total || event.Completed != progress[i].Completed ||
+			time.Since(event.Time) > time.Minute {
+			t.Errorf("Progress %+v is reported as '%s'", progress[i], line)
+		}
+		if progress[i].Total == 0 && strings.Contains(line, "total") {
+			t.Errorf("Unknown total is reported in '%s'", line)
+		}
+	}
+}
+
+// TestGenerateProgress checks that generator reports progress and completion
+func TestGenerateProgress(t *testing.T) {
+	reports := []Progress{}
+	generateTree(t, CreateNullDataGenerator(), t.TempDir(), 2, 3, 10,
+		WithProgress(func(p Progress) { reports = append(reports, p) }, time.Hour))
+	expected := Progress{Processed: 6, Total: 6, Completed: true}
+	if len(reports) != 1 || reports[0] != expected {
+		t.Errorf("Progress is reported as %+v instead of %+v", reports, expected)
+	}
+}
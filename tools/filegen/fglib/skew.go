@@ -0,0 +1,247 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Skewed distribution of operations among hot and cold files
+*/
+
+package fglib
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SkewEnum
+const (
+	SkewUniform = iota
+	SkewZipf
+	SkewHotset
+)
+
+// Skew describes distribution of operations among files. Files are ordered by hotness and the
+// hottest files get the most of operations
+type Skew struct {
+	Type     int     // SkewEnum
+	Exponent float64 // exponent of Zipfian distribution. Must be more than 1
+	HotOps   float64 // ratio of operations on hot files in (0;1)
+	HotFiles float64 // ratio of hot files in (0;1)
+}
+
+// ParseSkew parses skew in format: 'uniform', 'zipf{:exponent}' or 'hotset{:ops/files}'. For
+// example: 'zipf:1.5', 'hotset:90/10'. Defaults are 'zipf:1.2' and 'hotset:80/20'
+func ParseSkew(data string) (*Skew, error) {
+	parts := strings.SplitN(data, ":", 2)
+	switch parts[0] {
+	case "uniform":
+		if len(parts) == 2 {
+			return nil, fmt.Errorf("Uniform skew has no parameters")
+		}
+		return &Skew{Type: SkewUniform}, nil
+	case "zipf":
+		skew := &Skew{Type: SkewZipf, Exponent: 1.2}
+		if len(parts) == 2 {
+			var err error
+			skew.Exponent, err = strconv.ParseFloat(parts[1], 64)
+			if err != nil || skew.Exponent <= 1 || math.IsInf(skew.Exponent, 0) {
+				return nil, fmt.Errorf("Invalid exponent of Zipfian skew '%s'. Must be more than 1", parts[1])
+			}
+		}
+		return skew, nil
+	case "hotset":
+		skew := &Skew{Type: SkewHotset, HotOps: 0.8, HotFiles: 0.2}
+		if len(parts) == 2 {
+			ratios := strings.Split(parts[1], "/")
+			if len(ratios) != 2 {
+				return nil, fmt.Errorf("Invalid hotset '%s'. Must be 'ops/files' in percents", parts[1])
+			}
+			for i, dest := range []*float64{&skew.HotOps, &skew.HotFiles} {
+				value, err := strconv.ParseFloat(strings.TrimSuffix(ratios[i], "%"), 64)
+				if err != nil || value <= 0 || value >= 100 {
+					return nil, fmt.Errorf("Invalid hotset '%s'. Percents must be in (0;100)", parts[1])
+				}
+				*dest = value / 100
+			}
+		}
+		return skew, nil
+	}
+	return nil, fmt.Errorf("Invalid skew '%s'", data)
+}
+
+// weight returns relative weight of file with rank in [0;1). Rank 0 is the hottest file
+func (s *Skew) weight(rank float64, count uint64) float64 {
+	switch s.Type {
+	case SkewZipf:
+		return math.Pow(rank*float64(count)+1, -s.Exponent)
+	case SkewHotset:
+		if rank < s.HotFiles {
+			return s.HotOps / s.HotFiles
+		}
+		return (1 - s.HotOps) / (1 - s.HotFiles)
+	}
+	return 1
+}
+
+// getPosition returns position in [0;count) of list ordered by hotness
+func (s *Skew) getPosition(random *rand.Rand, count int) int {
+	switch s.Type {
+	case SkewZipf:
+		if count > 1 {
+			return int(rand.NewZipf(random, s.Exponent, 1, uint64(count-1)).Uint64())
+		}
+	case SkewHotset:
+		hot := int(float64(count) * s.HotFiles)
+		if hot > 0 && hot < count {
+			if random.Float64() < s.HotOps {
+				return random.Intn(hot)
+			}
+			return hot + random.Intn(count-hot)
+		}
+	}
+	return random.Intn(count)
+}
+
+/* Skewed selection of files to change */
+
+type selectionKey struct {
+	key   float64
+	index uint64
+}
+
+// selectionHeap keeps keys of selected files with the least key on top
+type selectionHeap []selectionKey
+
+func (h selectionHeap) Len() int            { return len(h) }
+func (h selectionHeap) Less(i, j int) bool  { return h[i].key < h[j].key }
+func (h selectionHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *selectionHeap) Push(x interface{}) { *h = append(*h, x.(selectionKey)) }
+func (h *selectionHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+type skewedFileSelector struct {
+	selected map[uint64]bool // indexes of selected files
+	index    uint64
+}
+
+func (s *skewedFileSelector) IsFileIsSelected() (bool, error) {
+	selected := s.selected[s.index]
+	s.index++
+	return selected, nil
+}
+
+// CreateSkewedFileSelector creates selector of count files from total files. Probability of file
+// to be selected depends on its rank got from hotSeed and index, so the same files are hot for
+// the same hotSeed. Random values for selection are got from seed or from generator if seed is nil
+func CreateSkewedFileSelector(gen DataGenerator, seed []byte, skew *Skew, hotSeed []byte, count uint64,
+	total uint64) (FileSelector, error) {
+	selector, err := CreateRundomFileSelector(gen, count, total)
+	if err != nil {
+		return nil, err
+	}
+	random := selector.(*randomFileSelector)
+	random.seed = seed
+
+	/* weighted sampling without replacement: files with the greatest keys log(u)/weight are selected */
+	keys := make(selectionHeap, 0, count)
+	for i := uint64(0); i < total && count > 0; i++ {
+		random.index = i
+		u, err := random.getFloat64()
+		if err != nil {
+			return nil, err
+		}
+		rank := float64(getHotRank(hotSeed, i)>>11) / float64(uint64(1)<<53)
+		key := selectionKey{key: math.Log(u) / skew.weight(rank, total), index: i}
+		if uint64(len(keys)) < count {
+			heap.Push(&keys, key)
+		} else if key.key > keys[0].key {
+			keys[0] = key
+			heap.Fix(&keys, 0)
+		}
+	}
+
+	selected := make(map[uint64]bool, len(keys))
+	for _, key := range keys {
+		selected[key.index] = true
+	}
+	return &skewedFileSelector{selected: selected}, nil
+}
+
+// getHotRank returns rank of hotness of file with index. File with the least rank is the hottest one
+func getHotRank(hotSeed []byte, index uint64) uint64 {
+	return binary.LittleEndian.Uint64(getIndexRandom(hotSeed, uint(index), 8))
+}
+
+/* Files ordered by hotness */
+
+type hotFile struct {
+	rank uint64
+	path string
+}
+
+// hotFiles keeps files ordered by rank of hotness, so position of file in list does not depend
+// on order of adding and removing files. Renamed file keeps its rank
+type hotFiles struct {
+	files []hotFile
+	ranks map[string]uint64
+}
+
+func createHotFiles() *hotFiles {
+	return &hotFiles{ranks: make(map[string]uint64)}
+}
+
+// find returns position of file with rank and path or position to insert it
+func (h *hotFiles) find(rank uint64, path string) int {
+	return sort.Search(len(h.files), func(i int) bool {
+		f := h.files[i]
+		return f.rank > rank || (f.rank == rank && f.path >= path)
+	})
+}
+
+func (h *hotFiles) add(path string, rank uint64) {
+	if _, ok := h.ranks[path]; ok {
+		return
+	}
+	i := h.find(rank, path)
+	h.files = append(h.files, hotFile{})
+	copy(h.files[i+1:], h.files[i:])
+	h.files[i] = hotFile{rank: rank, path: path}
+	h.ranks[path] = rank
+}
+
+// remove returns rank of removed file. It returns false if there is no file with path
+func (h *hotFiles) remove(path string) (uint64, bool) {
+	rank, ok := h.ranks[path]
+	if ok == false {
+		return 0, false
+	}
+	i := h.find(rank, path)
+	h.files = append(h.files[:i], h.files[i+1:]...)
+	delete(h.ranks, path)
+	return rank, true
+}
+
+func (h *hotFiles) rename(path string, newPath string) {
+	rank, ok := h.remove(path)
+	if ok {
+		h.add(newPath, rank)
+	}
+}
+
+func (h *hotFiles) len() int {
+	return len(h.files)
+}
+
+// get returns file at position. Position 0 is the hottest file
+func (h *hotFiles) get(position int) string {
+	return h.files[position].path
+}
@@ -0,0 +1,153 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Tests for skewed distribution of operations
+*/
+
+package fglib
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestParseSkew(t *testing.T) {
+	tests := []struct {
+		data  string
+		skew  *Skew
+		valid bool
+	}{
+		{"uniform", &Skew{Type: SkewUniform}, true},
+		{"zipf", &Skew{Type: SkewZipf, Exponent: 1.2}, true},
+		{"zipf:1.5", &Skew{Type: SkewZipf, Exponent: 1.5}, true},
+		{"hotset", &Skew{Type: SkewHotset, HotOps: 0.8, HotFiles: 0.2}, true},
+		{"hotset:90/10", &Skew{Type: SkewHotset, HotOps: 0.9, HotFiles: 0.1}, true},
+		{"hotset:90%/10%", &Skew{Type: SkewHotset, HotOps: 0.9, HotFiles: 0.1}, true},
+		{"uniform:1", nil, false},
+		{"zipf:1", nil, false},
+		{"zipf:x", nil, false},
+		{"hotset:90", nil, false},
+		{"hotset:100/10", nil, false},
+		{"hotset:90/0", nil, false},
+		{"normal", nil, false},
+	}
+	for _, test := range tests {
+		skew, err := ParseSkew(test.data)
+		if test.valid == false {
+			if err == nil {
+				t.Errorf("Invalid skew '%s' is parsed", test.data)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Failed to parse skew '%s': %v", test.data, err)
+		} else if reflect.DeepEqual(skew, test.skew) == false {
+			t.Errorf("Skew '%s' is parsed as %+v instead of %+v", test.data, skew, test.skew)
+		}
+	}
+}
+
+// TestSkewPositions checks ratio of operations on the hottest positions
+func TestSkewPositions(t *testing.T) {
+	const count, operations = 100, 100000
+	tests := []struct {
+		skew     Skew
+		hot      int     // count of the hottest positions
+		minRatio float64 // minimal ratio of operations on hot positions
+		maxRatio float64
+	}{
+		{Skew{Type: SkewUniform}, 20, 0.18, 0.22},
+		{Skew{Type: SkewHotset, HotOps: 0.8, HotFiles: 0.2}, 20, 0.78, 0.82},
+		{Skew{Type: SkewHotset, HotOps: 0.9, HotFiles: 0.1}, 10, 0.88, 0.92},
+		{Skew{Type: SkewZipf, Exponent: 1.2}, 1, 0.25, 0.45},
+		{Skew{Type: SkewZipf, Exponent: 2}, 1, 0.55, 0.7},
+	}
+	for _, test := range tests {
+		random := rand.New(rand.NewSource(1))
+		hits := make([]int, count)
+		for i := 0; i < operations; i++ {
+			position := test.skew.getPosition(random, count)
+			if position < 0 || position >= count {
+				t.Fatalf("Position %d is out of [0;%d)", position, count)
+			}
+			hits[position]++
+		}
+		hot := 0
+		for _, n := range hits[:test.hot] {
+			hot += n
+		}
+		ratio := float64(hot) / operations
+		if ratio < test.minRatio || ratio > test.maxRatio {
+			t.Errorf("Skew %+v: %.3f of operations on %d hot positions. Must be in [%.2f;%.2f]",
+				test.skew, ratio, test.hot, test.minRatio, test.maxRatio)
+		}
+		if test.skew.Type == SkewZipf && (hits[0] <= hits[1] || hits[1] <= hits[10]) {
+			t.Errorf("Skew %+v: hits are not decreasing: %d, %d, %d", test.skew, hits[0], hits[1], hits[10])
+		}
+	}
+}
+
+func TestSkewedFileSelector(t *testing.T) {
+	const count, total, runs = 10, 100, 300
+	hotSeed := SeedFromUint64(3)
+	skew := &Skew{Type: SkewHotset, HotOps: 0.8, HotFiles: 0.2}
+
+	/* the same files are selected for the same seeds */
+	var selections [2][]uint64
+	for i := range selections {
+		selector, err := CreateSkewedFileSelector(nil, SeedFromUint64(1), skew, hotSeed, count, total)
+		if err != nil {
+			t.Fatal(err)
+		}
+		selections[i] = getSelection(t, selector, total)
+		if len(selections[i]) != count {
+			t.Fatalf("%d files are selected instead of %d", len(selections[i]), count)
+		}
+	}
+	if reflect.DeepEqual(selections[0], selections[1]) == false {
+		t.Errorf("Different files are selected for the same seeds")
+	}
+
+	/* hot files are got from hotSeed and are selected more often */
+	hot := 0
+	for run := 0; run < runs; run++ {
+		selector, err := CreateSkewedFileSelector(nil, SeedFromUint64(uint64(run)), skew, hotSeed, count, total)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, i := range getSelection(t, selector, total) {
+			if float64(getHotRank(hotSeed, i)>>11)/float64(uint64(1)<<53) < skew.HotFiles {
+				hot++
+			}
+		}
+	}
+	ratio := float64(hot) / (runs * count)
+	if ratio < 0.6 {
+		t.Errorf("%.3f of selected files are hot. Must be more than 0.6", ratio)
+	}
+}
+
+// TestHotFilesOrder checks that files are ordered by rank after adding, removing and renaming
+func TestHotFilesOrder(t *testing.T) {
+	files := createHotFiles()
+	for i, path := range []string{"a", "b", "c", "d", "e", "f"} {
+		files.add(path, uint64(10-i))
+	}
+	files.remove("c")
+	files.remove("x")
+	files.rename("e", "g")
+	files.add("h", 0)
+	files.add("a", 1) // file is already added
+
+	expected := []string{"h", "f", "g", "d", "b", "a"}
+	if files.len() != len(expected) {
+		t.Fatalf("There are %d files instead of %d", files.len(), len(expected))
+	}
+	for i, path := range expected {
+		if files.get(i) != path {
+			t.Errorf("File '%s' is at position %d instead of '%s'", files.get(i), i, path)
+		}
+	}
+}
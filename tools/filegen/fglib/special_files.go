@@ -0,0 +1,114 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Links and special files selection
+*/
+
+package fglib
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// EntryEnum
+const (
+	EntryRegular = iota
+	EntrySymlink
+	EntryHardlink
+	EntryFifo
+	EntryEmpty
+)
+
+// SymlinkEnum
+const (
+	SymlinkRelative = iota
+	SymlinkAbsolute
+	SymlinkDangling
+	SymlinkLoop // link to parent directory
+)
+
+const maxTargetTries = 64 // count of files checked to find target of link
+
+// SpecialFiles selects type of entry for each file index. Selection depends on seed and index only
+type SpecialFiles struct {
+	seed  []byte
+	edges []float64 // cumulative ratios of EntryEnum values starting from EntrySymlink
+	kinds []int     // SymlinkEnum
+}
+
+// getRandom returns independent random values of index: 0 - entry type, 1 - symlink kind, 2 - target
+func (s *SpecialFiles) getRandom(index uint, salt uint) uint64 {
+	random := getIndexRandom(s.seed, index, 24)
+	return binary.LittleEndian.Uint64(random[salt*8:])
+}
+
+func (s *SpecialFiles) GetEntryType(index uint) int {
+	point := float64(s.getRandom(index, 0)) / float64(^uint64(0))
+	for i, edge := range s.edges {
+		if point < edge {
+			return EntrySymlink + i
+		}
+	}
+	return EntryRegular
+}
+
+// GetRatio returns ratio of entries of EntryEnum type except of regular files
+func (s *SpecialFiles) GetRatio(entryType int) float64 {
+	i := entryType - EntrySymlink
+	if i < 0 || i >= len(s.edges) {
+		return 0
+	}
+	if i == 0 {
+		return s.edges[0]
+	}
+	return s.edges[i] - s.edges[i-1]
+}
+
+func (s *SpecialFiles) GetSymlinkKind(index uint) int {
+	return s.kinds[int(s.getRandom(index, 1)%uint64(len(s.kinds)))]
+}
+
+// GetTarget returns index of regular file before index to link to. Only maxTargetTries files
+// before random one are checked, so link could have no target even if there are regular files
+func (s *SpecialFiles) GetTarget(index uint) (uint, bool) {
+	if index == 0 || s.edges[len(s.edges)-1] >= 1 {
+		return 0, false // there are no regular files
+	}
+	target := uint(s.getRandom(index, 2) % uint64(index))
+	for i := 0; i < maxTargetTries; i++ {
+		if s.GetEntryType(target) == EntryRegular {
+			return target, true
+		}
+		if target == 0 {
+			break
+		}
+		target--
+	}
+	return 0, false
+}
+
+// CreateSpecialFiles creates selector with ratios of entries types. Other files are regular
+func CreateSpecialFiles(seed []byte, symlinks, hardlinks, fifos, empty float64, kinds []int) (*SpecialFiles, error) {
+	s := &SpecialFiles{
+		seed:  seed,
+		kinds: kinds,
+	}
+
+	sum := float64(0)
+	for _, ratio := range []float64{symlinks, hardlinks, fifos, empty} {
+		if ratio < 0 || ratio > 1 {
+			return nil, fmt.Errorf("Ratio of special files must be in [0;1]. Got: %f", ratio)
+		}
+		sum += ratio
+		s.edges = append(s.edges, sum)
+	}
+	if sum > 1 {
+		return nil, fmt.Errorf("Sum of special files ratios must not be more than 1. Got: %f", sum)
+	}
+	if symlinks > 0 && len(kinds) == 0 {
+		return nil, fmt.Errorf("Symlinks kinds are not set")
+	}
+	return s, nil
+}
@@ -0,0 +1,196 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Tests for links and special files
+*/
+
+package fglib
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSpecialFilesRatios(t *testing.T) {
+	const count = 10000
+	tests := []struct {
+		ratios []float64 // symlinks, hardlinks, fifos, empty
+		valid  bool
+	}{
+		{[]float64{0.1, 0.2, 0.05, 0.15}, true},
+		{[]float64{0, 0.5, 0, 0}, true},
+		{[]float64{1, 0, 0, 0}, true},
+		{[]float64{-0.1, 0, 0, 0}, false},
+		{[]float64{0, 1.1, 0, 0}, false},
+		{[]float64{0.5, 0.3, 0.3, 0}, false},
+	}
+	for _, test := range tests {
+		special, err := CreateSpecialFiles(SeedFromUint64(1), test.ratios[0], test.ratios[1], test.ratios[2],
+			test.ratios[3], []int{SymlinkRelative})
+		if (err == nil) != test.valid {
+			t.Errorf("Special files with ratios %v are created with error: %v", test.ratios, err)
+		}
+		if err != nil {
+			continue
+		}
+		counts := make(map[int]int)
+		for i := uint(0); i < count; i++ {
+			counts[special.GetEntryType(i)]++
+		}
+		for i, ratio := range test.ratios {
+			entryType := EntrySymlink + i
+			if math.Abs(special.GetRatio(entryType)-ratio) > 1e-9 {
+				t.Errorf("Ratio of type %d is %f instead of %f", entryType, special.GetRatio(entryType), ratio)
+			}
+			if math.Abs(float64(counts[entryType])/count-ratio) > 0.02 {
+				t.Errorf("%d of %d entries have type %d with ratio %f", counts[entryType], count, entryType, ratio)
+			}
+		}
+	}
+	if _, err := CreateSpecialFiles(SeedFromUint64(1), 0.1, 0, 0, 0, nil); err == nil {
+		t.Errorf("Special files with symlinks without kinds are created")
+	}
+}
+
+// TestSpecialFilesTargets checks that targets are regular files before link and they do not depend on
+// kind of symlink
+func TestSpecialFilesTargets(t *testing.T) {
+	const count = 2000
+	special, err := CreateSpecialFiles(SeedFromUint64(2), 0.3, 0.2, 0.1, 0,
+		[]int{SymlinkRelative, SymlinkAbsolute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := special.GetTarget(0); ok {
+		t.Errorf("Target is found for the first file")
+	}
+	matching, total := 0, 0
+	for i := uint(1); i < count; i++ {
+		target, ok := special.GetTarget(i)
+		if ok == false {
+			continue
+		}
+		if target >= i || special.GetEntryType(target) != EntryRegular {
+			t.Fatalf("Target of file %d is %d with type %d", i, target, special.GetEntryType(target))
+		}
+		if i%2 == 0 {
+			total++
+			if int(target%2) == special.GetSymlinkKind(i) {
+				matching++
+			}
+		}
+	}
+	if ratio := float64(matching) / float64(total); ratio < 0.4 || ratio > 0.6 {
+		t.Errorf("Parity of targets matches kind of symlinks for %d of %d files", matching, total)
+	}
+}
+
+// TestSpecialFilesWithoutRegular checks that links have no targets if all files are special
+func TestSpecialFilesWithoutRegular(t *testing.T) {
+	const count = 100000
+	for _, ratios := range [][4]float64{{1, 0, 0, 0}, {0, 1, 0, 0}, {0.5, 0.5, 0, 0}, {0.3, 0.3, 0.2, 0.2}} {
+		special, err := CreateSpecialFiles(SeedFromUint64(4), ratios[0], ratios[1], ratios[2], ratios[3],
+			[]int{SymlinkRelative})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := uint(0); i < count; i++ {
+			if target, ok := special.GetTarget(i); ok {
+				t.Fatalf("Target %d of file %d is found with ratios %v", target, i, ratios)
+			}
+		}
+	}
+}
+
+// TestSpecialFilesRareRegular checks that targets are found by checking of limited count of files
+func TestSpecialFilesRareRegular(t *testing.T) {
+	const count = 10000
+	special, err := CreateSpecialFiles(SeedFromUint64(5), 0.95, 0, 0, 0, []int{SymlinkRelative})
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := 0
+	for i := uint(0); i < count; i++ {
+		target, ok := special.GetTarget(i)
+		if ok == false {
+			continue
+		}
+		found++
+		if target >= i || special.GetEntryType(target) != EntryRegular {
+			t.Fatalf("Target of file %d is %d with type %d", i, target, special.GetEntryType(target))
+		}
+	}
+	if found < count*9/10 {
+		t.Errorf("Targets are found for %d of %d files", found, count)
+	}
+}
+
+// TestGeneratedLinks checks that links in generated tree point to expected files
+func TestGeneratedLinks(t *testing.T) {
+	const dirs, files = 3, 20
+	kinds := []int{SymlinkRelative, SymlinkAbsolute, SymlinkDangling, SymlinkLoop}
+	special, err := CreateSpecialFiles(SeedFromUint64(3), 0.3, 0.3, 0, 0, kinds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	generateTree(t, CreateNullDataGenerator(), root, dirs, files, 100, WithSpecialFiles(special))
+
+	getPath := func(index uint) string {
+		return filepath.Join(root, fmt.Sprintf("dir_%d", index/files), fmt.Sprintf("file_%d", index%files))
+	}
+	links := make(map[int]int)
+	for i := uint(0); i < dirs*files; i++ {
+		path := getPath(i)
+		info, err := os.Lstat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entryType := special.GetEntryType(i)
+		target, hasTarget := special.GetTarget(i)
+		switch {
+		case entryType == EntrySymlink:
+			links[special.GetSymlinkKind(i)]++
+			link, err := os.Readlink(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := ".."
+			switch special.GetSymlinkKind(i) {
+			case SymlinkRelative, SymlinkAbsolute:
+				expected = "missing"
+				if hasTarget {
+					expected, _ = filepath.Rel(filepath.Dir(path), getPath(target))
+					if special.GetSymlinkKind(i) == SymlinkAbsolute {
+						expected = getPath(target)
+					}
+				}
+			case SymlinkDangling:
+				expected = fmt.Sprintf("missing_%d", i)
+			}
+			if link != expected {
+				t.Errorf("Symlink '%s' points to '%s' instead of '%s'", path, link, expected)
+			}
+		case entryType == EntryHardlink && hasTarget:
+			links[-1]++
+			targetInfo, err := os.Stat(getPath(target))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if os.SameFile(info, targetInfo) == false {
+				t.Errorf("Hard link '%s' is not linked to '%s'", path, getPath(target))
+			}
+		default:
+			if info.Mode().IsRegular() == false || info.Size() != 100 {
+				t.Errorf("File '%s' has mode %s and size %d", path, info.Mode(), info.Size())
+			}
+		}
+	}
+	if links[-1] == 0 || links[SymlinkRelative] == 0 || links[SymlinkAbsolute] == 0 {
+		t.Errorf("Links are not generated: %v", links)
+	}
+}
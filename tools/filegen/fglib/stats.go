@@ -0,0 +1,107 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Statistics of operations latency
+*/
+
+package fglib
+
+import (
+	"fmt"
+	"time"
+)
+
+// LatencyStats collects count and latency of operations. Percentiles are approximated with
+// buckets of powers of 2 nanoseconds
+type LatencyStats struct {
+	Count   uint64
+	Total   time.Duration
+	Min     time.Duration
+	Max     time.Duration
+	buckets [64]uint64
+}
+
+func (s *LatencyStats) Add(latency time.Duration) {
+	if s.Count == 0 || latency < s.Min {
+		s.Min = latency
+	}
+	if latency > s.Max {
+		s.Max = latency
+	}
+	s.Count++
+	s.Total += latency
+
+	bucket := 0
+	for v := uint64(latency); v > 1 && bucket < len(s.buckets)-1; v >>= 1 {
+		bucket++
+	}
+	s.buckets[bucket]++
+}
+
+func (s *LatencyStats) Merge(other *LatencyStats) {
+	if other.Count == 0 {
+		return
+	}
+	if s.Count == 0 || other.Min < s.Min {
+		s.Min = other.Min
+	}
+	if other.Max > s.Max {
+		s.Max = other.Max
+	}
+	s.Count += other.Count
+	s.Total += other.Total
+	for i, count := range other.buckets {
+		s.buckets[i] += count
+	}
+}
+
+func (s *LatencyStats) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+// Percentile returns upper bound of latency for percent of operations. Percent is in [0;100]
+func (s *LatencyStats) Percentile(percent float64) time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	need := uint64(float64(s.Count) * percent / 100)
+	if need == 0 {
+		need = 1
+	}
+	count := uint64(0)
+	for i, bucketCount := range s.buckets {
+		count += bucketCount
+		if count >= need {
+			bound := time.Duration(uint64(1) << uint(i+1))
+			if bound > s.Max {
+				return s.Max
+			}
+			return bound
+		}
+	}
+	return s.Max
+}
+
+func (s *LatencyStats) String() string {
+	return fmt.Sprintf("min %v, avg %v, p50 %v, p99 %v, max %v", s.Min, s.Mean(), s.Percentile(50),
+		s.Percentile(99), s.Max)
+}
+
+// FormatThroughput returns bytes per second in human readable format
+func FormatThroughput(bytes uint64, duration time.Duration) string {
+	if duration <= 0 {
+		return "0 B/s"
+	}
+	value := float64(bytes) / duration.Seconds()
+	units := []string{"B/s", "KiB/s", "MiB/s", "GiB/s", "TiB/s"}
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", value, units[i])
+}
@@ -0,0 +1,100 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Stream of generated data to writer
+*/
+
+package fglib
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+type DataStreamer interface {
+	Stream() error
+	// StreamContext stops streaming when context is done and returns error of context
+	StreamContext(ctx context.Context) error
+	Stop() // stops streaming. Stream returns ErrCanceled
+}
+
+type dataStreamer struct {
+	options
+	gen    DataGenerator
+	writer io.Writer
+	size   uint64 // infinite stream if 0
+
+	stop     chan bool
+	stopOnce sync.Once
+}
+
+func (s *dataStreamer) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+func (s *dataStreamer) stream(ctx context.Context, written *uint64) error {
+	var bufferSize uint64 = 1024 * 1024
+	buffer := make([]byte, bufferSize)
+	for s.size == 0 || atomic.LoadUint64(written) < s.size {
+		if isStopped(ctx) {
+			return ErrCanceled
+		}
+		if s.size > 0 && s.size-atomic.LoadUint64(written) < bufferSize {
+			buffer = buffer[:s.size-atomic.LoadUint64(written)]
+		}
+		_, err := s.gen.Read(buffer)
+		if err != nil {
+			return errors.Wrap(err, "Failed to generate data")
+		}
+		n, err := s.writer.Write(buffer)
+		atomic.AddUint64(written, uint64(n))
+		if err != nil {
+			return errors.Wrap(err, "Failed to write data")
+		}
+	}
+	return nil
+}
+
+func (s *dataStreamer) Stream() error {
+	return s.StreamContext(context.Background())
+}
+
+func (s *dataStreamer) StreamContext(parent context.Context) error {
+	ctx, cancel := stopContext(parent, s.stop)
+	defer cancel()
+
+	written := uint64(0)
+	err := s.runWithProgress(func() error {
+		return s.stream(ctx, &written)
+	}, func() Progress {
+		return Progress{Processed: atomic.LoadUint64(&written), Total: s.size}
+	})
+	if err != nil {
+		return errors.Wrap(getCanceledError(parent, err), "Failed to stream data")
+	}
+	return nil
+}
+
+// CreateDataStreamer creates streamer of size bytes from generator to writer. Stream is infinite
+// if size is 0. Progress is reported in bytes. Generator and writer are not closed by streamer
+func CreateDataStreamer(gen DataGenerator, writer io.Writer, size uint64,
+	opts ...Option) (DataStreamer, error) {
+	options, err := getOptions(componentStreamer, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &dataStreamer{
+		options: options,
+		gen:     gen,
+		writer:  writer,
+		size:    size,
+		stop:    make(chan bool),
+	}, nil
+}
@@ -0,0 +1,113 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Tests for stream of generated data
+*/
+
+package fglib
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// limitedWriter accepts limit bytes and fails after it. Callback is called on each write
+type limitedWriter struct {
+	bytes.Buffer
+	limit   int
+	onWrite func()
+}
+
+func (w *limitedWriter) Write(data []byte) (int, error) {
+	if w.onWrite != nil {
+		w.onWrite()
+	}
+	if w.Len()+len(data) > w.limit {
+		n, _ := w.Buffer.Write(data[:w.limit-w.Len()])
+		return n, fmt.Errorf("Writer is full")
+	}
+	return w.Buffer.Write(data)
+}
+
+func TestStreamSize(t *testing.T) {
+	const mb = 1024 * 1024
+	for _, size := range []uint64{1, 1000, mb - 1, mb, mb + 1, 3*mb + 7} {
+		gen, err := CreatePseudoRandomDataGenerator(SeedFromUint64(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var reported Progress
+		var output bytes.Buffer
+		streamer, err := CreateDataStreamer(gen, &output, size,
+			WithProgress(func(p Progress) { reported = p }, time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = streamer.Stream()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uint64(output.Len()) != size {
+			t.Errorf("%d bytes are streamed instead of %d", output.Len(), size)
+		}
+		if reported != (Progress{Processed: size, Total: size, Completed: true}) {
+			t.Errorf("Progress of stream of %d bytes is reported as %+v", size, reported)
+		}
+
+		expected := make([]byte, size)
+		gen, _ = CreatePseudoRandomDataGenerator(SeedFromUint64(1))
+		gen.Read(expected)
+		if bytes.Equal(output.Bytes(), expected) == false {
+			t.Errorf("Stream of %d bytes differs from generated data", size)
+		}
+	}
+}
+
+// TestInfiniteStream checks that stream without size is written until writer fails or streaming is stopped
+func TestInfiniteStream(t *testing.T) {
+	writer := &limitedWriter{limit: 5*1024*1024 + 3}
+	streamer, err := CreateDataStreamer(CreateNullDataGenerator(), writer, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = streamer.Stream()
+	if err == nil || writer.Len() != writer.limit {
+		t.Errorf("Infinite stream is stopped after %d bytes with error: %v", writer.Len(), err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	writer = &limitedWriter{limit: 1 << 40, onWrite: cancel}
+	var reported Progress
+	streamer, err = CreateDataStreamer(CreateNullDataGenerator(), writer, 0,
+		WithProgress(func(p Progress) { reported = p }, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = streamer.StreamContext(ctx)
+	if errors.Cause(err) != context.Canceled {
+		t.Errorf("Canceled stream returned error: %v", err)
+	}
+	if reported.Processed != uint64(writer.Len()) || reported.Total != 0 || reported.Completed == false {
+		t.Errorf("Progress of %d streamed bytes is reported as %+v", writer.Len(), reported)
+	}
+}
+
+func TestStopStream(t *testing.T) {
+	streamer, err := CreateDataStreamer(CreateNullDataGenerator(), io.Discard, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamer.Stop()
+	streamer.Stop() // repeated stop is allowed
+	err = streamer.Stream()
+	if errors.Cause(err) != ErrCanceled {
+		t.Errorf("Stopped stream returned error: %v", err)
+	}
+}
@@ -0,0 +1,340 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Differences of files between two trees
+*/
+
+package fglib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// DiffEnum
+const (
+	DiffAdded = iota
+	DiffRemoved
+	DiffModified
+)
+
+var diffNames = []string{"added", "removed", "modified"}
+
+// FileDiff describes difference of file. Size is -1 if file does not exist in tree
+type FileDiff struct {
+	Name   string      `json:"path"` // relative path with slashes
+	Type   int         `json:"-"`    // DiffEnum
+	SizeA  int64       `json:"size_a"`
+	SizeB  int64       `json:"size_b"`
+	Ranges []ByteRange `json:"ranges,omitempty"` // differing ranges of modified file
+}
+
+func (d FileDiff) MarshalJSON() ([]byte, error) {
+	type fileDiff FileDiff // has no MarshalJSON method
+	return json.Marshal(struct {
+		Change string `json:"change"`
+		fileDiff
+	}{diffNames[d.Type], fileDiff(d)})
+}
+
+func (d FileDiff) String() string {
+	switch d.Type {
+	case DiffAdded:
+		return "+ " + d.Name
+	case DiffRemoved:
+		return "- " + d.Name
+	}
+	ranges := make([]string, 0, len(d.Ranges))
+	for _, r := range d.Ranges {
+		ranges = append(ranges, fmt.Sprintf("%d-%d", r.Offset, r.Offset+r.Length))
+	}
+	return fmt.Sprintf("M %s %s", d.Name, strings.Join(ranges, ","))
+}
+
+type DiffStats struct {
+	Added     uint64
+	Removed   uint64
+	Modified  uint64
+	Unchanged uint64
+	DiffBytes uint64 // length of differing ranges of modified files
+}
+
+type TreeDiffer interface {
+	// Diff calls report for each differing file in order of paths
+	Diff(report func(diff FileDiff) error) (*DiffStats, error)
+	// DiffContext stops comparison when context is done and returns error of context
+	DiffContext(ctx context.Context, report func(diff FileDiff) error) (*DiffStats, error)
+	Stop() // stops comparison. Diff returns ErrCanceled
+}
+
+type diffEntry struct {
+	name string // relative path with slashes
+	size int64
+}
+
+// compareNames compares paths by items as filepath.Walk orders them
+func compareNames(a, b string) int {
+	itemsA, itemsB := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(itemsA) && i < len(itemsB); i++ {
+		if itemsA[i] != itemsB[i] {
+			if itemsA[i] < itemsB[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(itemsA) - len(itemsB)
+}
+
+type treeDiffer struct {
+	options
+	pathA     string
+	pathB     string
+	blockSize int
+
+	files uint64 // atomic counter of compared files
+
+	stop     chan bool
+	stopOnce sync.Once
+}
+
+func (d *treeDiffer) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.stop)
+	})
+}
+
+// walk sends regular files of root to entries in order of filepath.Walk
+func (d *treeDiffer) walk(ctx context.Context, root string, entries chan diffEntry) error {
+	defer close(entries)
+	return filepath.Walk(root, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() == false {
+			return nil
+		}
+		name, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to get relative path for '%s'", filePath)
+		}
+		select {
+		case entries <- diffEntry{name: filepath.ToSlash(name), size: info.Size()}:
+			return nil
+		case <-ctx.Done():
+			return ErrCanceled
+		}
+	})
+}
+
+// compareFiles returns differing ranges aligned to blocks. Adjacent blocks are merged
+func (d *treeDiffer) compareFiles(ctx context.Context, name string, sizeA, sizeB int64, bufferA,
+	bufferB []byte) ([]ByteRange, error) {
+	fileA, err := os.Open(filepath.Join(d.pathA, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to open '%s'", name)
+	}
+	defer fileA.Close()
+	fileB, err := os.Open(filepath.Join(d.pathB, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to open '%s'", name)
+	}
+	defer fileB.Close()
+
+	var ranges []ByteRange
+	add := func(offset, length int64) {
+		last := len(ranges) - 1
+		if last >= 0 && ranges[last].Offset+ranges[last].Length == offset {
+			ranges[last].Length += length
+			return
+		}
+		ranges = append(ranges, ByteRange{Offset: offset, Length: length})
+	}
+
+	common := sizeA
+	if sizeB < common {
+		common = sizeB
+	}
+	blockSize := int64(d.blockSize)
+	for offset := int64(0); offset < common; offset += blockSize {
+		if isStopped(ctx) {
+			return nil, ErrCanceled
+		}
+		length := blockSize
+		if common-offset < length {
+			length = common - offset
+		}
+		_, err := io.ReadFull(fileA, bufferA[:length])
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to read '%s' in '%s'", name, d.pathA)
+		}
+		_, err = io.ReadFull(fileB, bufferB[:length])
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to read '%s' in '%s'", name, d.pathB)
+		}
+		if bytes.Equal(bufferA[:length], bufferB[:length]) == false {
+			add(offset, length)
+		}
+	}
+
+	/* data after the end of the shorter file differs */
+	if sizeA != sizeB {
+		longest := sizeA
+		if sizeB > longest {
+			longest = sizeB
+		}
+		add(common, longest-common)
+	}
+	return ranges, nil
+}
+
+func (d *treeDiffer) diff(ctx context.Context, stats *DiffStats, report func(diff FileDiff) error) error {
+	for _, root := range []string{d.pathA, d.pathB} {
+		info, err := os.Stat(root)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to get info of '%s'", root)
+		}
+		if info.IsDir() == false {
+			return fmt.Errorf("'%s' is not a directory", root)
+		}
+	}
+
+	walkCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	entriesA, entriesB := make(chan diffEntry, 1024), make(chan diffEntry, 1024)
+	walkErrors := make(chan error, 2)
+	for _, walk := range []struct {
+		root    string
+		entries chan diffEntry
+	}{{d.pathA, entriesA}, {d.pathB, entriesB}} {
+		go func(root string, entries chan diffEntry) {
+			err := d.walk(walkCtx, root, entries)
+			if err != nil {
+				err = errors.Wrapf(err, "Failed to walk '%s'", root)
+			}
+			walkErrors <- err
+		}(walk.root, walk.entries)
+	}
+
+	err := d.merge(ctx, entriesA, entriesB, stats, report)
+	cancel() // stops walking if comparison is failed
+	for i := 0; i < 2; i++ {
+		walkErr := <-walkErrors
+		if err == nil && walkErr != nil && errors.Cause(walkErr) != ErrCanceled {
+			err = walkErr
+		}
+	}
+	if err == nil && isStopped(ctx) {
+		err = ErrCanceled
+	}
+	return err
+}
+
+// merge compares ordered entries of both trees
+func (d *treeDiffer) merge(ctx context.Context, entriesA, entriesB chan diffEntry, stats *DiffStats,
+	report func(diff FileDiff) error) error {
+	bufferA, bufferB := make([]byte, d.blockSize), make([]byte, d.blockSize)
+	a, okA := <-entriesA
+	b, okB := <-entriesB
+	for okA || okB {
+		if isStopped(ctx) {
+			return ErrCanceled
+		}
+
+		order := 0
+		switch {
+		case okA == false:
+			order = 1
+		case okB == false:
+			order = -1
+		default:
+			order = compareNames(a.name, b.name)
+		}
+
+		var err error
+		switch {
+		case order < 0:
+			stats.Removed++
+			err = report(FileDiff{Name: a.name, Type: DiffRemoved, SizeA: a.size, SizeB: -1})
+			a, okA = <-entriesA
+		case order > 0:
+			stats.Added++
+			err = report(FileDiff{Name: b.name, Type: DiffAdded, SizeA: -1, SizeB: b.size})
+			b, okB = <-entriesB
+		default:
+			var ranges []ByteRange
+			ranges, err = d.compareFiles(ctx, a.name, a.size, b.size, bufferA, bufferB)
+			if err != nil {
+				return err
+			}
+			if len(ranges) == 0 {
+				stats.Unchanged++
+			} else {
+				stats.Modified++
+				for _, r := range ranges {
+					stats.DiffBytes += uint64(r.Length)
+				}
+				err = report(FileDiff{Name: a.name, Type: DiffModified, SizeA: a.size, SizeB: b.size,
+					Ranges: ranges})
+			}
+			a, okA = <-entriesA
+			b, okB = <-entriesB
+		}
+		if err != nil {
+			return errors.Wrap(err, "Failed to report difference")
+		}
+		atomic.AddUint64(&d.files, 1)
+	}
+	return nil
+}
+
+func (d *treeDiffer) Diff(report func(diff FileDiff) error) (*DiffStats, error) {
+	return d.DiffContext(context.Background(), report)
+}
+
+func (d *treeDiffer) DiffContext(parent context.Context, report func(diff FileDiff) error) (*DiffStats, error) {
+	ctx, cancel := stopContext(parent, d.stop)
+	defer cancel()
+
+	stats := &DiffStats{}
+	err := d.runWithProgress(func() error {
+		return d.diff(ctx, stats, report)
+	}, func() Progress {
+		return Progress{Processed: atomic.LoadUint64(&d.files)}
+	})
+	if err != nil {
+		return stats, errors.Wrap(getCanceledError(parent, err), "Failed to compare trees")
+	}
+	return stats, nil
+}
+
+// CreateTreeDiffer creates comparer of regular files of trees in pathA and pathB. Data of files is
+// compared by blocks of blockSize, so differing ranges are aligned to blocks
+func CreateTreeDiffer(pathA, pathB string, blockSize int, opts ...Option) (TreeDiffer, error) {
+	if blockSize <= 0 {
+		return nil, fmt.Errorf("Block size must be positive")
+	}
+	options, err := getOptions(componentDiffer, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &treeDiffer{
+		options:   options,
+		pathA:     pathA,
+		pathB:     pathB,
+		blockSize: blockSize,
+		stop:      make(chan bool),
+	}, nil
+}
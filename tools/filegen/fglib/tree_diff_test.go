@@ -0,0 +1,157 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Tests for differences of trees
+*/
+
+package fglib
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFileDiffOutput(t *testing.T) {
+	tests := []struct {
+		diff FileDiff
+		text string
+		json string
+	}{
+		{FileDiff{Name: "dir_0/file_1", Type: DiffAdded, SizeA: -1, SizeB: 10}, "+ dir_0/file_1",
+			`{"change":"added","path":"dir_0/file_1","size_a":-1,"size_b":10}`},
+		{FileDiff{Name: "file", Type: DiffRemoved, SizeA: 5, SizeB: -1}, "- file",
+			`{"change":"removed","path":"file","size_a":5,"size_b":-1}`},
+		{FileDiff{Name: "file", Type: DiffModified, SizeA: 300, SizeB: 350,
+			Ranges: []ByteRange{{Offset: 0, Length: 100}, {Offset: 200, Length: 150}}}, "M file 0-100,200-350",
+			`{"change":"modified","path":"file","size_a":300,"size_b":350,` +
+				`"ranges":[{"offset":0,"length":100},{"offset":200,"length":150}]}`},
+	}
+	for _, test := range tests {
+		if test.diff.String() != test.text {
+			t.Errorf("Difference is printed as '%s' instead of '%s'", test.diff.String(), test.text)
+		}
+		data, err := json.Marshal(test.diff)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != test.json {
+			t.Errorf("Difference is encoded as '%s' instead of '%s'", data, test.json)
+		}
+	}
+}
+
+func TestCompareNames(t *testing.T) {
+	tests := []struct {
+		a     string
+		b     string
+		order int
+	}{
+		{"a", "a", 0},
+		{"a", "b", -1},
+		{"a/b", "a.txt", -1}, // items of path are compared as filepath.Walk orders them
+		{"a/b/c", "a/b", 1},
+		{"dir_10/file", "dir_2/file", -1},
+	}
+	for _, test := range tests {
+		order := compareNames(test.a, test.b)
+		if (order < 0 && test.order >= 0) || (order > 0 && test.order <= 0) || (order == 0) != (test.order == 0) {
+			t.Errorf("Order of '%s' and '%s' is %d instead of %d", test.a, test.b, order, test.order)
+		}
+	}
+}
+
+// TestTreeDiff checks differences of trees in order of paths with ranges aligned to blocks
+func TestTreeDiff(t *testing.T) {
+	block := strings.Repeat("x", 100)
+	changed := strings.Repeat("y", 100)
+	treeA := map[string]string{
+		"a/b":          block,
+		"a.txt":        block,
+		"same":         block + block,
+		"removed":      "data",
+		"dir/modified": block + block + block + block,
+		"dir/appended": block + "x",
+		"dir/shorter":  block + block + block,
+		"dir/empty":    "",
+	}
+	treeB := map[string]string{
+		"a/b":          block,
+		"a.txt":        "x" + changed[1:],
+		"same":         block + block,
+		"added/file":   "data",
+		"dir/modified": changed + block + "x" + changed[1:] + changed,
+		"dir/appended": block + "x" + block,
+		"dir/shorter":  block + block[:50],
+		"dir/empty":    "",
+	}
+	rootA, rootB := t.TempDir(), t.TempDir()
+	writeTreeFiles(t, rootA, treeA)
+	writeTreeFiles(t, rootB, treeB)
+	os.Symlink("same", filepath.Join(rootB, "link")) // only regular files are compared
+
+	differ, err := CreateTreeDiffer(rootA, rootB, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := []string{}
+	stats, err := differ.Diff(func(diff FileDiff) error {
+		output = append(output, diff.String())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"M a.txt 0-100",
+		"+ added/file",
+		"M dir/appended 101-201",
+		"M dir/modified 0-100,200-400",
+		"M dir/shorter 150-300", // the last common block is shorter and equal
+		"- removed",
+	}
+	if reflect.DeepEqual(output, expected) == false {
+		t.Errorf("Differences are\n%s\ninstead of\n%s", strings.Join(output, "\n"), strings.Join(expected, "\n"))
+	}
+	expectedStats := DiffStats{Added: 1, Removed: 1, Modified: 4, Unchanged: 3, DiffBytes: 100 + 100 + 300 + 150}
+	if *stats != expectedStats {
+		t.Errorf("Statistics is %+v instead of %+v", *stats, expectedStats)
+	}
+}
+
+func TestTreeDiffErrors(t *testing.T) {
+	root := t.TempDir()
+	writeTreeFiles(t, root, map[string]string{"file": "data"})
+	if _, err := CreateTreeDiffer(root, root, 0); err == nil {
+		t.Errorf("Differ with zero block size is created")
+	}
+
+	tests := []struct {
+		pathA  string
+		pathB  string
+		report func(diff FileDiff) error
+	}{
+		{root, filepath.Join(root, "file"), nil},
+		{filepath.Join(root, "missing"), root, nil},
+		{t.TempDir(), root, func(diff FileDiff) error { return fmt.Errorf("Failed to print") }},
+	}
+	for _, test := range tests {
+		differ, err := CreateTreeDiffer(test.pathA, test.pathB, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		report := test.report
+		if report == nil {
+			report = func(diff FileDiff) error { return nil }
+		}
+		_, err = differ.Diff(report)
+		if err == nil {
+			t.Errorf("Difference of '%s' and '%s' is computed without error", test.pathA, test.pathB)
+		}
+	}
+}
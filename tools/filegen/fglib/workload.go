@@ -0,0 +1,436 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Time-based workload of mixed operations over existing files
+*/
+
+package fglib
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// OperationEnum
+const (
+	OperationCreate = iota
+	OperationOverwrite
+	OperationAppend
+	OperationRead
+	OperationDelete
+	OperationRename
+	OperationStat
+	OperationsCount
+)
+
+var operationNames = []string{"create", "overwrite", "append", "read", "delete", "rename", "stat"}
+
+func GetOperationName(operation int) string {
+	return operationNames[operation]
+}
+
+// OperationsMix selects operations with weights
+type OperationsMix struct {
+	operations []int
+	edges      []float64 // cumulative weights normalized to 1
+}
+
+// ParseOperationsMix parses mix in format 'operation:weight{%},...'. For example: 'read:70%,create:30%'
+func ParseOperationsMix(data string) (*OperationsMix, error) {
+	mix := &OperationsMix{}
+	var weights []float64
+	total := float64(0)
+	for _, item := range strings.Split(data, ",") {
+		parts := strings.Split(item, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid operation format '%s'. Must be 'operation:weight'", item)
+		}
+
+		operation := -1
+		for i, name := range operationNames {
+			if name == parts[0] {
+				operation = i
+			}
+		}
+		if operation < 0 {
+			return nil, fmt.Errorf("Invalid operation '%s'", parts[0])
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSuffix(parts[1], "%"), 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to parse weight of operation '%s'", item)
+		}
+		if weight < 0 || math.IsInf(weight, 0) || math.IsNaN(weight) {
+			return nil, fmt.Errorf("Invalid weight of operation '%s'. Must be positive", item)
+		}
+		mix.operations = append(mix.operations, operation)
+		weights = append(weights, weight)
+		total += weight
+	}
+
+	if total == 0 {
+		return nil, fmt.Errorf("Sum of operations weights must be positive")
+	}
+	sum := float64(0)
+	for _, weight := range weights {
+		sum += weight
+		mix.edges = append(mix.edges, sum/total)
+	}
+	return mix, nil
+}
+
+// GetOperation returns operation for point in [0;1)
+func (m *OperationsMix) GetOperation(point float64) int {
+	for i, edge := range m.edges {
+		if point < edge {
+			return m.operations[i]
+		}
+	}
+	return m.operations[len(m.operations)-1]
+}
+
+/* Workload */
+
+type OperationStats struct {
+	Latency LatencyStats
+	Bytes   uint64 // written or read bytes
+	Errors  uint64
+}
+
+type WorkloadStats struct {
+	Duration   time.Duration
+	Operations [OperationsCount]OperationStats // indexed by OperationEnum
+}
+
+type Workload interface {
+	Run() (*WorkloadStats, error)
+	// RunContext stops workload when context is done and returns error of context
+	RunContext(ctx context.Context) (*WorkloadStats, error)
+	Stop() // stops workload before duration is passed. Run returns ErrCanceled
+}
+
+// lockedGenerator allows to read data from generator in several goroutines
+type lockedGenerator struct {
+	DataGenerator
+	guard sync.Mutex
+}
+
+func (g *lockedGenerator) Read(p []byte) (int, error) {
+	g.guard.Lock()
+	defer g.guard.Unlock()
+	return g.DataGenerator.Read(p)
+}
+
+type workload struct {
+	options
+	modifier    *modifyFilesWithIntervals // overwrites files with interval
+	gen         DataGenerator
+	path        string
+	mix         *OperationsMix
+	duration    time.Duration
+	fileSize    uint64
+	blockSize   int
+	concurrency int
+
+	guard   sync.Mutex
+	files   *hotFiles
+	indexed uint64 // count of existing and created files. Rank of hotness depends on index of file
+
+	prefix     string // prefix of created files names
+	created    uint64
+	operations uint64
+
+	stop     chan bool
+	stopOnce sync.Once
+}
+
+func (w *workload) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+}
+
+// addFile adds file with rank of hotness got from index. Ranks are random with skew and they are
+// the same as ranks of skewed selection of change command
+func (w *workload) addFile(path string, index uint64) {
+	w.guard.Lock()
+	defer w.guard.Unlock()
+	rank := index
+	if w.skew != nil {
+		rank = getHotRank(w.hotSeed, index)
+	}
+	w.files.add(path, rank)
+}
+
+func (w *workload) removeFile(path string) {
+	w.guard.Lock()
+	defer w.guard.Unlock()
+	w.files.remove(path) // file could be removed by other goroutine
+}
+
+func (w *workload) renameFile(path string, newPath string) {
+	w.guard.Lock()
+	defer w.guard.Unlock()
+	w.files.rename(path, newPath)
+}
+
+// getFile returns random file. Hot files are selected more often with skew. It returns false if
+// there are no files
+func (w *workload) getFile(random *rand.Rand) (string, bool) {
+	w.guard.Lock()
+	defer w.guard.Unlock()
+	if w.files.len() == 0 {
+		return "", false
+	}
+	if w.skew != nil {
+		return w.files.get(w.skew.getPosition(random, w.files.len())), true
+	}
+	return w.files.get(random.Intn(w.files.len())), true
+}
+
+func (w *workload) getNewName(dir string) string {
+	return filepath.Join(dir, fmt.Sprintf("%s_%d", w.prefix, atomic.AddUint64(&w.created, 1)))
+}
+
+// runOperation returns count of written or read bytes
+func (w *workload) runOperation(ctx context.Context, operation int, path string, buffer []byte) (uint64, error) {
+	switch operation {
+	case OperationCreate:
+		dir := w.path
+		if path != "" {
+			dir = filepath.Dir(path)
+		}
+		newPath := w.getNewName(dir)
+		err := writeFile(ctx, newPath, w.fileSize, nil, w.gen)
+		if err != nil {
+			os.Remove(newPath)
+			return 0, err
+		}
+		w.addFile(newPath, atomic.AddUint64(&w.indexed, 1)-1)
+		return w.fileSize, nil
+	case OperationOverwrite:
+		info, err := os.Stat(path)
+		if err != nil {
+			return 0, err
+		}
+		written := uint64(0)
+		err = w.modifier.changeFile(ctx, path, info.Size(), &written)
+		return written, err
+	case OperationAppend:
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return 0, err
+		}
+		defer file.Close()
+		err = copyData(ctx, file, uint64(len(buffer)), nil, w.gen)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(len(buffer)), nil
+	case OperationRead:
+		file, err := os.Open(path)
+		if err != nil {
+			return 0, err
+		}
+		defer file.Close()
+		read := uint64(0)
+		_, err = io.CopyBuffer(ioutil.Discard, &contextReader{ctx: ctx, reader: file, read: &read}, buffer)
+		return read, err
+	case OperationDelete:
+		err := os.Remove(path)
+		if err == nil || os.IsNotExist(err) {
+			w.removeFile(path)
+		}
+		return 0, err
+	case OperationRename:
+		newPath := w.getNewName(filepath.Dir(path))
+		err := os.Rename(path, newPath)
+		if err == nil {
+			w.renameFile(path, newPath)
+		}
+		return 0, err
+	case OperationStat:
+		_, err := os.Stat(path)
+		return 0, err
+	}
+	return 0, fmt.Errorf("Invalid operation %d", operation)
+}
+
+func (w *workload) runWorker(ctx context.Context, worker int, stats *WorkloadStats, guard *sync.Mutex) {
+	var local WorkloadStats
+	random := rand.New(rand.NewSource(time.Now().UnixNano() + int64(worker)))
+	buffer := make([]byte, w.blockSize)
+	for isStopped(ctx) == false {
+		operation := w.mix.GetOperation(random.Float64())
+		path, ok := w.getFile(random)
+		if ok == false && operation != OperationCreate {
+			operation = OperationCreate // there are no files to process
+		}
+
+		start := time.Now()
+		bytes, err := w.runOperation(ctx, operation, path, buffer)
+		if errors.Cause(err) == ErrCanceled {
+			break // not completed operation is not counted
+		}
+		opStats := &local.Operations[operation]
+		opStats.Latency.Add(time.Since(start))
+		opStats.Bytes += bytes
+		if err != nil {
+			if opStats.Errors == 0 {
+				log.Printf("Failed to %s '%s': %s\n", GetOperationName(operation), path, err)
+			}
+			opStats.Errors++
+		}
+		atomic.AddUint64(&w.operations, 1)
+	}
+
+	guard.Lock()
+	defer guard.Unlock()
+	for i := range stats.Operations {
+		stats.Operations[i].Latency.Merge(&local.Operations[i].Latency)
+		stats.Operations[i].Bytes += local.Operations[i].Bytes
+		stats.Operations[i].Errors += local.Operations[i].Errors
+	}
+}
+
+// selectFiles selects files for operations with selector of change command. Skew is applied to
+// operations on selected files, so files are selected uniformly
+func (w *workload) selectFiles(ctx context.Context) error {
+	m := w.modifier
+	selector := CreateAllFilesSelector()
+	if m.isSelective() {
+		total, err := m.getFilesCount(ctx)
+		if err != nil {
+			return errors.Wrap(err, "Failed to get files count")
+		}
+		selector, _, err = m.createFileSelector(uint64(total), nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return filepath.Walk(w.path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if isStopped(ctx) {
+			return ErrCanceled
+		}
+		changeable, err := m.isChangeable(path, info)
+		if err != nil || changeable == false {
+			return err
+		}
+		index := w.indexed
+		w.indexed++
+		selected, err := selector.IsFileIsSelected()
+		if err != nil {
+			return errors.Wrap(err, "Failed to check if file is selected")
+		}
+		if selected {
+			w.addFile(path, index)
+		}
+		return nil
+	})
+}
+
+func (w *workload) run(ctx context.Context, stats *WorkloadStats) error {
+	err := w.selectFiles(ctx)
+	if err != nil {
+		return errors.Wrap(err, "Failed to select files")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, w.duration)
+	defer cancel()
+	var guard sync.Mutex
+	var completed sync.WaitGroup
+	for i := 0; i < w.concurrency; i++ {
+		completed.Add(1)
+		go func(worker int) {
+			defer completed.Done()
+			w.runWorker(ctx, worker, stats, &guard)
+		}(i)
+	}
+	completed.Wait()
+	return nil
+}
+
+func (w *workload) Run() (*WorkloadStats, error) {
+	return w.RunContext(context.Background())
+}
+
+func (w *workload) RunContext(parent context.Context) (*WorkloadStats, error) {
+	ctx, cancel := stopContext(parent, w.stop)
+	defer cancel()
+
+	stats := &WorkloadStats{}
+	start := time.Now()
+	err := w.runWithProgress(func() error {
+		return w.run(ctx, stats)
+	}, func() Progress {
+		return Progress{Processed: atomic.LoadUint64(&w.operations)}
+	})
+	stats.Duration = time.Since(start)
+	if err == nil && isStopped(ctx) {
+		err = ErrCanceled // stopped before duration is passed
+	}
+	if err != nil {
+		return stats, errors.Wrap(getCanceledError(parent, err), "Failed to run workload")
+	}
+	return stats, nil
+}
+
+// CreateWorkload creates workload of operations selected by mix for duration in concurrency
+// goroutines. Operations are run over changeRatio of existing files selected as change command
+// does and created files. Files of fileSize are created, blocks of blockSize are read and appended,
+// files are overwritten with interval. Generator is not closed by workload
+func CreateWorkload(gen DataGenerator, path string, changeRatio float64, mix *OperationsMix,
+	duration time.Duration, fileSize uint64, blockSize int, concurrency int, interval Interval, once,
+	reverse bool, opts ...Option) (Workload, error) {
+	if duration <= 0 || blockSize <= 0 || concurrency <= 0 {
+		return nil, fmt.Errorf("Duration, block size and concurrency must be positive")
+	}
+
+	options, err := getOptions(componentWorkload, opts)
+	if err != nil {
+		return nil, err
+	}
+	modifierOptions := options
+	modifierOptions.journal = nil // written ranges are not stored by workload
+	locked := &lockedGenerator{DataGenerator: gen}
+	return &workload{
+		options: options,
+		modifier: &modifyFilesWithIntervals{
+			options:     modifierOptions,
+			gen:         locked,
+			path:        path,
+			changeRatio: changeRatio,
+			interval:    interval,
+			once:        once,
+			reverse:     reverse,
+		},
+		gen:         locked,
+		path:        path,
+		mix:         mix,
+		duration:    duration,
+		fileSize:    fileSize,
+		blockSize:   blockSize,
+		concurrency: concurrency,
+		files:       createHotFiles(),
+		prefix:      fmt.Sprintf("workload_%x", time.Now().UnixNano()),
+		stop:        make(chan bool),
+	}, nil
+}
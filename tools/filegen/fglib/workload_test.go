@@ -0,0 +1,85 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Tests for workload of mixed operations
+*/
+
+package fglib
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// getWorkloadFiles returns files selected by workload for operations
+func getWorkloadFiles(t *testing.T, path string, changeRatio float64, opts ...Option) []string {
+	mix, err := ParseOperationsMix("stat:100%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := CreateWorkload(CreateNullDataGenerator(), path, changeRatio, mix, time.Second, 1024, 1024, 1,
+		Interval{}, false, false, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.(*workload).selectFiles(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []string{}
+	for _, file := range w.(*workload).files.files {
+		name, err := filepath.Rel(path, file.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, filepath.ToSlash(name))
+	}
+	return files
+}
+
+func TestWorkloadSelectFiles(t *testing.T) {
+	dir := t.TempDir()
+	tree := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("file_%d.txt", i)
+		if i%2 == 1 {
+			name = fmt.Sprintf("file_%d.bin", i)
+		}
+		tree[name] = ""
+	}
+	writeTreeFiles(t, dir, tree)
+
+	tests := []struct {
+		ratio    float64
+		opts     []Option
+		count    int
+		matching string // suffix of all selected files
+	}{
+		{1, nil, 20, ""},
+		{0.5, []Option{WithSelectionSeed(SeedFromUint64(1))}, 10, ""},
+		{1, []Option{WithSelectedCount(3), WithSelectionSeed(SeedFromUint64(1))}, 3, ""},
+		{1, []Option{WithFilter(&FileFilter{Extensions: []string{".txt"}})}, 10, ".txt"},
+		{0.2, []Option{WithFilter(&FileFilter{Extensions: []string{".bin"}}), WithSelectionSeed(SeedFromUint64(2))},
+			2, ".bin"},
+	}
+	for _, test := range tests {
+		files := getWorkloadFiles(t, dir, test.ratio, test.opts...)
+		if len(files) != test.count {
+			t.Errorf("%d files are selected instead of %d: %v", len(files), test.count, files)
+		}
+		for _, file := range files {
+			if strings.HasSuffix(file, test.matching) == false {
+				t.Errorf("File '%s' does not match filter", file)
+			}
+		}
+		if reflect.DeepEqual(files, getWorkloadFiles(t, dir, test.ratio, test.opts...)) == false {
+			t.Errorf("Different files are selected for the same options")
+		}
+	}
+}
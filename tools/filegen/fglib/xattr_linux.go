@@ -0,0 +1,16 @@
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Extended attributes for Linux
+*/
+
+package fglib
+
+import (
+	"syscall"
+)
+
+func setXattr(path string, name string, value []byte) error {
+	return syscall.Setxattr(path, name, value, 0)
+}
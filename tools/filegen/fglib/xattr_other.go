@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+/*
+Author:    Alexey Osorgin (alexey.osorgin@gmail.com)
+Copyright: Alexey Osorgin, 2018
+
+Brief:     Extended attributes are supported on Linux only
+*/
+
+package fglib
+
+func setXattr(path string, name string, value []byte) error {
+	return ErrNotSupported
+}
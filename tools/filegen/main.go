@@ -8,36 +8,261 @@ Brief:     Tool to generate files
 package main
 
 import (
+	"encoding/json"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
 
 	"github.com/aosorgin/gotools/tools/filegen/fglib"
 	"github.com/pkg/errors"
 )
 
-func getGenerator() (fglib.DataGenerator, error) {
-	if fglib.Options.GeneratorType == fglib.GeneratorCrypto {
-		return fglib.CreateMutliThreadGenerator(fglib.CreateCryptoDataGenerator(), fglib.CreateUnorderedQueue())
-	} else if fglib.Options.GeneratorType == fglib.GeneratorPseudo {
-		dataGen, err := fglib.CreatePseudoRandomDataGenerator(fglib.Options.Seed)
+// Exit codes
+const (
+	exitSuccess  = 0
+	exitFailure  = 1
+	exitCanceled = 2 // used if signal number is unknown
+)
+
+// getDataGenerator returns single-thread generator of type
+func getDataGenerator(genType int, seed []byte) (fglib.DataGenerator, error) {
+	if genType == fglib.GeneratorCrypto {
+		return fglib.CreateCryptoDataGenerator(), nil
+	} else if genType == fglib.GeneratorPseudo {
+		dataGen, err := fglib.CreatePseudoRandomDataGenerator(seed)
 		if err != nil {
 			return nil, errors.Wrap(err, "Failed to create pseudo-random generator")
 		}
-		return fglib.CreateMutliThreadGenerator(dataGen, fglib.CreateOrderedQueue())
-	} else if fglib.Options.GeneratorType == fglib.GeneratorNull {
-		return fglib.CreateMutliThreadGenerator(fglib.CreateNullDataGenerator(), fglib.CreateUnorderedQueue())
+		return dataGen, nil
+	} else if genType == fglib.GeneratorNull {
+		return fglib.CreateNullDataGenerator(), nil
+	} else if genType == fglib.GeneratorXoshiro {
+		return fglib.CreateXoshiroDataGenerator(seed)
+	} else if genType == fglib.GeneratorChaCha8 {
+		return fglib.CreateChaCha8DataGenerator(seed)
+	} else if genType == fglib.GeneratorAESCTR {
+		return fglib.CreateAESCTRDataGenerator(seed)
 	}
 
 	panic("Invalid generator type")
 }
 
-func generateFiles(options *fglib.CmdOptions) {
-	gen, err := getGenerator()
+// getMultiThreadGenerator returns generator of type using all CPUs. Data of seeded generators
+// is ordered to get the same data for the same seed
+func getMultiThreadGenerator(genType int, seed []byte) (fglib.DataGenerator, error) {
+	dataGen, err := getDataGenerator(genType, seed)
+	if err != nil {
+		return nil, err
+	}
+	queue := fglib.CreateUnorderedQueue()
+	if fglib.IsSeededGenerator(genType) {
+		queue = fglib.CreateOrderedQueue()
+	}
+	return fglib.CreateMutliThreadGenerator(dataGen, queue)
+}
+
+func getGenerator(options *fglib.CmdOptions) (fglib.DataGenerator, error) {
+	return getMultiThreadGenerator(options.GeneratorType, options.Seed)
+}
+
+// getSeed returns seed for random names and layout. Each label gives different seed
+func getSeed(options *fglib.CmdOptions, label string) []byte {
+	seed := options.Seed
+	if seed == nil {
+		seed = fglib.SeedFromUint64(0)
+	}
+	return append(append([]byte{}, seed...), label...)
+}
+
+func getNameGenerator(cmdOptions *fglib.CmdOptions, namesType int, label string, prefix,
+	suffix string, dirs bool) (fglib.NameGenerator, error) {
+	options := &cmdOptions.Names
+
+	/* names are the same for the same seed. Directories and files use different seeds */
+	seed := getSeed(cmdOptions, label)
+
+	var names fglib.NameGenerator
+	switch namesType {
+	case fglib.NamesIndex:
+		names = fglib.CreateIndexNameGenerator()
+	case fglib.NamesRandom:
+		length := options.Length
+		if length == 0 {
+			length = fglib.NameMax - cmdOptions.GetNameAffixLength(dirs)
+		}
+		var err error
+		names, err = fglib.CreateRandomNameGenerator(seed, length, options.Chars)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to create random names generator")
+		}
+	case fglib.NamesUUID:
+		names = fglib.CreateUUIDNameGenerator(seed)
+	default:
+		panic("Invalid names type")
+	}
+	return fglib.CreateAffixNameGenerator(names, prefix, suffix), nil
+}
+
+// getAttributes returns nil if attributes are not set. Manifest should be closed by caller
+func getAttributes(cmdOptions *fglib.CmdOptions) (*fglib.Attributes, *fglib.Manifest, error) {
+	options := &cmdOptions.Attributes
+	if options.Xattrs == 0 && options.ACLEntries == 0 {
+		return nil, nil, nil
+	}
+
+	var manifest *fglib.Manifest
+	if options.Manifest != "" {
+		var err error
+		manifest, err = fglib.CreateManifest(options.Manifest)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	attrs, err := fglib.CreateAttributes(getSeed(cmdOptions, "attributes"),
+		int(options.Xattrs), int(options.ValueSize), int(options.ACLEntries), manifest)
+	if err != nil {
+		if manifest != nil {
+			manifest.Close()
+		}
+		return nil, nil, err
+	}
+	return attrs, manifest, nil
+}
+
+func closeManifest(manifest *fglib.Manifest) {
+	if manifest == nil {
+		return
+	}
+	err := manifest.Close()
+	if err != nil {
+		log.Print(errors.Wrap(err, "Failed to close manifest"))
+	}
+}
+
+type stopper interface {
+	Stop()
+}
+
+// stopOnSignal stops processing on SIGINT or SIGTERM. Received signal is sent to returned channel.
+// The next signal terminates the process immediately.
+func stopOnSignal(s stopper) chan os.Signal {
+	signals := make(chan os.Signal, 1)
+	received := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		signal.Reset(syscall.SIGINT, syscall.SIGTERM)
+		log.Printf("Received %s signal. Stopping...\n", sig)
+		received <- sig
+		s.Stop()
+	}()
+	return received
+}
+
+// getExitCode returns 128 + signal number if processing is canceled by signal as shells do
+func getExitCode(err error, received chan os.Signal) int {
+	if err == nil {
+		return exitSuccess
+	}
+	if errors.Cause(err) == fglib.ErrCanceled {
+		log.Print("Processing is canceled")
+		/* signal is sent before processing is stopped, so it is received if it stopped processing */
+		select {
+		case sig := <-received:
+			if number, ok := sig.(syscall.Signal); ok {
+				return 128 + int(number)
+			}
+		default:
+		}
+		return exitCanceled
+	}
+	log.Print(err)
+	return exitFailure
+}
+
+func generateFiles(options *fglib.CmdOptions) int {
+	gen, err := getGenerator(options)
 	if err != nil {
 		log.Print(errors.Wrap(err, "Failed to initialize generator"))
+		return exitFailure
+	}
+
+	opts := []fglib.Option{fglib.WithProgressReporter(
+		fglib.CreateProgressReporter(options.Progress, options.GetProgressOutput(), "Generated", "generate"), options.ProgressInterval)}
+
+	checkpoint := options.Generate.ResumeFrom
+	if checkpoint == nil && options.Generate.Checkpoint != "" {
+		checkpoint = fglib.CreateCheckpoint(options.Generate.Checkpoint, options.GeneratorType, options.Seed,
+			options.GetLayoutHash(), options.Generate.Folders, options.Generate.Files, options.Generate.FileSize)
+	}
+	if checkpoint != nil {
+		opts = append(opts, fglib.WithCheckpoint(checkpoint))
+	}
+
+	dirNames, err := getNameGenerator(options, options.Names.DirType, "dirs", options.Names.DirPrefix, "", true)
+	if err != nil {
+		log.Print(errors.Wrap(err, "Failed to initialize directories names"))
+		return exitFailure
+	}
+	fileNames, err := getNameGenerator(options, options.Names.FileType, "files", options.Names.FilePrefix,
+		options.Names.FileSuffix+options.Names.FileExt, false)
+	if err != nil {
+		log.Print(errors.Wrap(err, "Failed to initialize files names"))
+		return exitFailure
+	}
+	if options.Names.FileTypes != nil {
+		fileNames = fglib.CreateFileTypesNameGenerator(fileNames, options.Names.FileTypes, getSeed(options, "types"))
+	}
+	opts = append(opts, fglib.WithDirNames(fglib.CreateNestedNameGenerator(dirNames, options.Names.Depth)),
+		fglib.WithFileNames(fileNames))
+
+	if options.Generate.Symlinks+options.Generate.Hardlinks+options.Generate.Fifos+options.Generate.Empty > 0 {
+		special, err := fglib.CreateSpecialFiles(getSeed(options, "special"),
+			options.Generate.Symlinks, options.Generate.Hardlinks, options.Generate.Fifos, options.Generate.Empty,
+			options.Generate.SymlinkKinds)
+		if err != nil {
+			log.Print(errors.Wrap(err, "Failed to initialize special files"))
+			return exitFailure
+		}
+		opts = append(opts, fglib.WithSpecialFiles(special))
+	}
+
+	attrs, manifest, err := getAttributes(options)
+	if err != nil {
+		log.Print(errors.Wrap(err, "Failed to initialize attributes"))
+		return exitFailure
+	}
+	defer closeManifest(manifest)
+	if attrs != nil {
+		opts = append(opts, fglib.WithAttributes(attrs))
+	}
+
+	if len(options.Permissions.FileModes) > 0 || len(options.Permissions.DirModes) > 0 ||
+		options.Permissions.Uids.Min >= 0 || options.Permissions.Gids.Min >= 0 {
+		perms, err := fglib.CreatePermissions(getSeed(options, "permissions"),
+			options.Permissions.FileModes, options.Permissions.DirModes, options.Permissions.Uids,
+			options.Permissions.Gids)
+		if err != nil {
+			log.Print(errors.Wrap(err, "Failed to initialize permissions"))
+			return exitFailure
+		}
+		opts = append(opts, fglib.WithPermissions(perms))
+	}
+
+	if options.Generate.Output != "" {
+		return generateArchive(options, gen, opts)
+	}
+
+	filesGen, err := fglib.CreateLinearFileGenerator(gen, options.Path, options.Generate.Folders,
+		options.Generate.Files, options.Generate.FileSize, opts...)
+	if err != nil {
+		log.Print(errors.Wrap(err, "Failed to initialize files generator"))
+		return exitFailure
 	}
-	filesGen := fglib.CreateLinearFileGenerator(gen, options.Path,
-		options.Generate.Folders, fglib.CreatePrefixNameGenerator("dir_"),
-		options.Generate.Files, fglib.CreatePrefixNameGenerator("file_"), options.Generate.FileSize)
 
 	defer func() {
 		err = filesGen.Close()
@@ -46,20 +271,123 @@ func generateFiles(options *fglib.CmdOptions) {
 		}
 	}()
 
+	received := stopOnSignal(filesGen)
 	err = filesGen.Generate()
 	if err != nil {
-		log.Print(errors.Wrap(err, "Failed to generate files"))
+		err = errors.Wrap(err, "Failed to generate files")
+	}
+	return getExitCode(err, received)
+}
+
+// generateArchive writes archive to file or stdout. Not completed archive file is removed
+func generateArchive(options *fglib.CmdOptions, gen fglib.DataGenerator, opts []fglib.Option) int {
+	defer func() {
+		err := gen.Close()
+		if err != nil {
+			log.Print(errors.Wrap(err, "Failed to close generator"))
+		}
+	}()
+
+	output := os.Stdout
+	if options.Generate.Output != "-" {
+		file, err := os.Create(options.Generate.Output)
+		if err != nil {
+			log.Print(errors.Wrap(err, "Failed to create archive"))
+			return exitFailure
+		}
+		output = file
+	}
+
+	exitCode := exitFailure
+	filesGen, err := fglib.CreateArchiveFileGenerator(gen, output, options.Generate.Archive,
+		options.Generate.Folders, options.Generate.Files, options.Generate.FileSize, opts...)
+	if err != nil {
+		log.Print(errors.Wrap(err, "Failed to initialize archive generator"))
+	} else {
+		received := stopOnSignal(filesGen)
+		err = filesGen.Generate()
+		if err != nil {
+			err = errors.Wrap(err, "Failed to generate files")
+		}
+		exitCode = getExitCode(err, received)
+	}
+
+	if output != os.Stdout {
+		err = output.Close()
+		if err != nil && exitCode == exitSuccess {
+			log.Print(errors.Wrap(err, "Failed to close archive"))
+			exitCode = exitFailure
+		}
+		if exitCode != exitSuccess {
+			os.Remove(output.Name())
+		}
 	}
+	return exitCode
 }
 
-func changeFiles(options *fglib.CmdOptions) {
-	gen, err := getGenerator()
+// changeFiles changes files. Seeded generator is used in one thread if journal is set to get
+// data of written ranges from stream command output
+func changeFiles(options *fglib.CmdOptions) int {
+	var gen fglib.DataGenerator
+	var err error
+	if fglib.IsSeededGenerator(options.GeneratorType) && options.Change.Journal != "" {
+		gen, err = getDataGenerator(options.GeneratorType, options.Seed)
+	} else {
+		gen, err = getGenerator(options)
+	}
 	if err != nil {
 		log.Print(errors.Wrap(err, "Failed to initialize generator"))
+		return exitFailure
+	}
+
+	opts := []fglib.Option{fglib.WithProgressReporter(
+		fglib.CreateProgressReporter(options.Progress, options.GetProgressOutput(), "Processed", "change"), options.ProgressInterval)}
+	attrs, manifest, err := getAttributes(options)
+	if err != nil {
+		log.Print(errors.Wrap(err, "Failed to initialize attributes"))
+		return exitFailure
+	}
+	defer closeManifest(manifest)
+	if attrs != nil {
+		opts = append(opts, fglib.WithAttributes(attrs))
 	}
 
-	modifier := fglib.CreateFilesModifierWithInterval(gen, options.Path, options.Change.Ratio,
-		options.Change.Interval, options.Change.Once, options.Change.Reverse)
+	if options.Change.Journal != "" {
+		var seed []byte
+		if fglib.IsSeededGenerator(options.GeneratorType) {
+			seed = options.Seed
+		}
+		journal, err := fglib.CreateJournal(options.Change.Journal, fglib.GetGeneratorName(options.GeneratorType),
+			seed)
+		if err != nil {
+			log.Print(errors.Wrap(err, "Failed to initialize journal"))
+			return exitFailure
+		}
+		defer func() {
+			err := journal.Close()
+			if err != nil {
+				log.Print(errors.Wrap(err, "Failed to close journal"))
+			}
+		}()
+		opts = append(opts, fglib.WithJournal(journal))
+	}
+	opts = append(opts, fglib.WithFilter(&options.Change.Filter))
+	if options.Change.Count > 0 {
+		opts = append(opts, fglib.WithSelectedCount(uint64(options.Change.Count)))
+	}
+	if options.Seed != nil {
+		opts = append(opts, fglib.WithSelectionSeed(getSeed(options, "select")))
+	}
+	if options.Skew.Type != fglib.SkewUniform {
+		opts = append(opts, fglib.WithSkew(options.Skew, getSeed(options, "hot")))
+	}
+
+	modifier, err := fglib.CreateFilesModifierWithInterval(gen, options.Path, options.Change.Ratio,
+		options.Change.Interval, options.Change.Once, options.Change.Reverse, opts...)
+	if err != nil {
+		log.Print(errors.Wrap(err, "Failed to initialize files modifier"))
+		return exitFailure
+	}
 
 	defer func() {
 		err = modifier.Close()
@@ -68,19 +396,237 @@ func changeFiles(options *fglib.CmdOptions) {
 		}
 	}()
 
+	received := stopOnSignal(modifier)
 	err = modifier.Modify()
 	if err != nil {
-		log.Print(errors.Wrap(err, "Failed to modify files"))
+		err = errors.Wrap(err, "Failed to modify files")
+	}
+	return getExitCode(err, received)
+}
+
+// streamData writes data to stdout. Seeded generator is used in one thread to get the same data
+// for the same seed
+func streamData(options *fglib.CmdOptions) int {
+	var gen fglib.DataGenerator
+	var err error
+	if fglib.IsSeededGenerator(options.GeneratorType) {
+		gen, err = getDataGenerator(options.GeneratorType, options.Seed)
+	} else {
+		gen, err = getGenerator(options)
+	}
+	if err != nil {
+		log.Print(errors.Wrap(err, "Failed to initialize generator"))
+		return exitFailure
+	}
+	defer func() {
+		err = gen.Close()
+		if err != nil {
+			log.Print(errors.Wrap(err, "Failed to close generator"))
+		}
+	}()
+
+	streamer, err := fglib.CreateDataStreamer(gen, os.Stdout, options.Stream.Size, fglib.WithProgressReporter(
+		fglib.CreateProgressReporter(options.Progress, options.GetProgressOutput(), "Streamed bytes", "stream"),
+		options.ProgressInterval))
+	if err != nil {
+		log.Print(errors.Wrap(err, "Failed to initialize streamer"))
+		return exitFailure
 	}
+	received := stopOnSignal(streamer)
+	return getExitCode(streamer.Stream(), received)
+}
+
+// readFiles reads files and prints statistics. Statistics is printed for canceled reading too
+func readFiles(options *fglib.CmdOptions) int {
+	opts := []fglib.Option{fglib.WithProgressReporter(
+		fglib.CreateProgressReporter(options.Progress, options.GetProgressOutput(), "Read files", "read"),
+		options.ProgressInterval)}
+	if options.Read.Verify {
+		gen, err := fglib.CreatePseudoRandomDataGenerator(options.Seed)
+		if err != nil {
+			log.Print(errors.Wrap(err, "Failed to initialize generator"))
+			return exitFailure
+		}
+		defer gen.Close()
+		opts = append(opts, fglib.WithVerification(gen.(fglib.FileDataGenerator)))
+	}
+	if options.Seed != nil {
+		opts = append(opts, fglib.WithOffsetsSeed(getSeed(options, "offsets")))
+	}
+
+	reader, err := fglib.CreateFilesReader(options.Path, options.Read.Access, int(options.Read.BlockSize),
+		int(options.Read.Concurrency), opts...)
+	if err != nil {
+		log.Print(errors.Wrap(err, "Failed to initialize reader"))
+		return exitFailure
+	}
+
+	received := stopOnSignal(reader)
+	stats, err := reader.Read()
+	fmt.Printf("Read: %d files, %d bytes in %v\n", stats.Files, stats.Bytes, stats.Duration)
+	fmt.Printf("Throughput: %s, %.0f reads/s\n", fglib.FormatThroughput(stats.Bytes, stats.Duration),
+		float64(stats.Latency.Count)/stats.Duration.Seconds())
+	fmt.Printf("Latency: %s\n", stats.Latency.String())
+	return getExitCode(err, received)
+}
+
+// runWorkload runs workload and prints statistics of each operation. Statistics is printed for
+// canceled workload too
+func runWorkload(options *fglib.CmdOptions) int {
+	gen, err := getGenerator(options)
+	if err != nil {
+		log.Print(errors.Wrap(err, "Failed to initialize generator"))
+		return exitFailure
+	}
+	defer func() {
+		err = gen.Close()
+		if err != nil {
+			log.Print(errors.Wrap(err, "Failed to close generator"))
+		}
+	}()
+
+	opts := []fglib.Option{fglib.WithProgressReporter(
+		fglib.CreateProgressReporter(options.Progress, options.GetProgressOutput(), "Operations", "workload"),
+		options.ProgressInterval)}
+	opts = append(opts, fglib.WithFilter(&options.Change.Filter))
+	if options.Change.Count > 0 {
+		opts = append(opts, fglib.WithSelectedCount(uint64(options.Change.Count)))
+	}
+	if options.Seed != nil {
+		opts = append(opts, fglib.WithSelectionSeed(getSeed(options, "select")))
+	}
+	if options.Skew.Type != fglib.SkewUniform {
+		opts = append(opts, fglib.WithSkew(options.Skew, getSeed(options, "hot")))
+	}
+
+	workload, err := fglib.CreateWorkload(gen, options.Path, options.Change.Ratio, options.Workload.Mix,
+		options.Workload.Duration, options.Workload.FileSize, int(options.Read.BlockSize),
+		int(options.Read.Concurrency), options.Change.Interval, options.Change.Once, options.Change.Reverse, opts...)
+	if err != nil {
+		log.Print(errors.Wrap(err, "Failed to initialize workload"))
+		return exitFailure
+	}
+
+	received := stopOnSignal(workload)
+	stats, err := workload.Run()
+	fmt.Printf("Workload: %v\n", stats.Duration)
+	fmt.Printf("%-10s %10s %10s %12s %8s  %s\n", "Operation", "Count", "Rate/s", "Bytes", "Errors", "Latency")
+	for operation := range stats.Operations {
+		opStats := &stats.Operations[operation]
+		if opStats.Latency.Count == 0 {
+			continue
+		}
+		fmt.Printf("%-10s %10d %10.1f %12d %8d  %s\n", fglib.GetOperationName(operation), opStats.Latency.Count,
+			float64(opStats.Latency.Count)/stats.Duration.Seconds(), opStats.Bytes, opStats.Errors,
+			opStats.Latency.String())
+	}
+	return getExitCode(err, received)
+}
+
+// diffTrees prints differences of trees. Summary is printed to stderr for JSON output and for
+// canceled comparison too
+func diffTrees(options *fglib.CmdOptions) int {
+	differ, err := fglib.CreateTreeDiffer(options.Diff.PathA, options.Diff.PathB, int(options.Read.BlockSize),
+		fglib.WithProgressReporter(
+			fglib.CreateProgressReporter(options.Progress, options.GetProgressOutput(), "Compared files", "diff"),
+			options.ProgressInterval))
+	if err != nil {
+		log.Print(errors.Wrap(err, "Failed to initialize comparison"))
+		return exitFailure
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	received := stopOnSignal(differ)
+	stats, err := differ.Diff(func(diff fglib.FileDiff) error {
+		if options.Diff.JSON {
+			return encoder.Encode(diff)
+		}
+		_, err := fmt.Println(diff.String())
+		return err
+	})
+	if err != nil && errors.Cause(err) != fglib.ErrCanceled {
+		return getExitCode(err, received)
+	}
+	summary := os.Stdout
+	if options.Diff.JSON {
+		summary = os.Stderr
+	}
+	fmt.Fprintf(summary, "Added: %d, removed: %d, modified: %d, unchanged: %d files. Differing data: %d bytes\n",
+		stats.Added, stats.Removed, stats.Modified, stats.Unchanged, stats.DiffBytes)
+	return getExitCode(err, received)
+}
+
+// benchGenerators prints throughput of each generator in one thread and in all CPUs
+func benchGenerators(options *fglib.CmdOptions) int {
+	bench, err := fglib.CreateGeneratorBench(options.Bench.Duration, int(options.Read.BlockSize))
+	if err != nil {
+		log.Print(errors.Wrap(err, "Failed to initialize bench"))
+		return exitFailure
+	}
+	received := stopOnSignal(bench)
+
+	fmt.Printf("%-10s %8s %10s\n", "Generator", "Threads", "GB/s")
+	for genType := 0; genType < fglib.GeneratorsCount; genType++ {
+		for _, multiThread := range []bool{false, true} {
+			var gen fglib.DataGenerator
+			threads := 1
+			if multiThread {
+				gen, err = getMultiThreadGenerator(genType, getSeed(options, ""))
+				threads = runtime.NumCPU()
+			} else {
+				gen, err = getDataGenerator(genType, getSeed(options, ""))
+			}
+			if err != nil {
+				log.Print(errors.Wrap(err, "Failed to initialize generator"))
+				return exitFailure
+			}
+
+			result, err := bench.Run(gen)
+			closeErr := gen.Close()
+			if err != nil {
+				return getExitCode(err, received)
+			}
+			if closeErr != nil {
+				log.Print(errors.Wrap(closeErr, "Failed to close generator"))
+			}
+			fmt.Printf("%-10s %8d %10.2f\n", fglib.GetGeneratorName(genType), threads, result.Throughput())
+		}
+	}
+	return exitSuccess
 }
 
 func main() {
-	options := fglib.ParseCmdOptions()
-	switch fglib.Options.Command {
+	options, err := fglib.ParseCmdOptions()
+	if err == fglib.ErrHelp {
+		fglib.Usage(os.Stdout)
+		os.Exit(exitSuccess)
+	}
+	if err == fglib.ErrVersion {
+		fmt.Println("Version: " + fglib.Version)
+		os.Exit(exitSuccess)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n\n", err)
+		fglib.Usage(os.Stderr)
+		os.Exit(exitFailure)
+	}
+
+	exitCode := exitSuccess
+	switch options.Command {
 	case fglib.CommandGenerate:
-		generateFiles(options)
+		exitCode = generateFiles(options)
 	case fglib.CommandChange:
-		changeFiles(options)
+		exitCode = changeFiles(options)
+	case fglib.CommandRead:
+		exitCode = readFiles(options)
+	case fglib.CommandStream:
+		exitCode = streamData(options)
+	case fglib.CommandWorkload:
+		exitCode = runWorkload(options)
+	case fglib.CommandDiff:
+		exitCode = diffTrees(options)
+	case fglib.CommandBench:
+		exitCode = benchGenerators(options)
 	}
-
+	os.Exit(exitCode)
 }